@@ -0,0 +1,98 @@
+package telemetry
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// backoffMaxMultiplier caps how far the effective metric export
+// interval is stretched under sustained collector throttling.
+const backoffMaxMultiplier = 8
+
+// backoffState stretches the effective metric export interval while the
+// collector signals overload. Each throttled export doubles the
+// multiplier applied to the base interval, up to the cap; each
+// successful export halves it again, so the pipeline returns to full
+// rate once the collector catches up.
+type backoffState struct {
+	mu         sync.Mutex
+	base       time.Duration
+	multiplier int
+	next       time.Time
+}
+
+// skip reports whether an export arriving now falls inside the
+// stretched interval and must be dropped.
+func (s *backoffState) skip(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return now.Before(s.next)
+}
+
+// observe feeds an export outcome into the backoff. A throttling error
+// stretches the interval; anything else shrinks it back toward the
+// base.
+func (s *backoffState) observe(now time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if throttledError(err) {
+		if s.multiplier < 2 {
+			s.multiplier = 2
+		} else if s.multiplier < backoffMaxMultiplier {
+			s.multiplier *= 2
+		}
+	} else if s.multiplier > 1 {
+		s.multiplier /= 2
+	}
+	if s.multiplier > 1 {
+		s.next = now.Add(s.base * time.Duration(s.multiplier-1))
+	} else {
+		s.next = time.Time{}
+	}
+}
+
+// interval returns the effective export interval currently in force.
+func (s *backoffState) interval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.multiplier > 1 {
+		return s.base * time.Duration(s.multiplier)
+	}
+	return s.base
+}
+
+// throttledError reports whether the export error is the collector
+// signaling overload, as opposed to an outage or a data problem.
+func throttledError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if status.Code(err) == codes.ResourceExhausted {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "throttl")
+}
+
+// backoffMetricExporter drops export ticks that fall inside the
+// stretched interval and feeds every real attempt's outcome back into
+// the shared state.
+type backoffMetricExporter struct {
+	sdkmetric.Exporter
+	state *backoffState
+}
+
+func (e *backoffMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if e.state.skip(time.Now()) {
+		return nil
+	}
+	err := e.Exporter.Export(ctx, rm)
+	e.state.observe(time.Now(), err)
+	return err
+}
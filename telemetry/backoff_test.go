@@ -0,0 +1,109 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// throttlingMetricExporter fails a fixed number of exports with a
+// ResourceExhausted status before recovering.
+type throttlingMetricExporter struct {
+	countingMetricExporter
+	failures int
+}
+
+func (e *throttlingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.calls++
+	if e.failures > 0 {
+		e.failures--
+		return status.Error(codes.ResourceExhausted, "collector throttled")
+	}
+	return nil
+}
+
+func TestBackoffStretchesAndRecovers(t *testing.T) {
+	state := &backoffState{base: time.Second}
+	now := time.Now()
+
+	if got := state.interval(); got != time.Second {
+		t.Fatalf("initial interval = %v, want 1s", got)
+	}
+
+	// throttling errors stretch the interval, doubling up to the cap.
+	throttle := status.Error(codes.ResourceExhausted, "collector throttled")
+	state.observe(now, throttle)
+	if got := state.interval(); got != 2*time.Second {
+		t.Errorf("interval after one throttle = %v, want 2s", got)
+	}
+	state.observe(now, throttle)
+	state.observe(now, throttle)
+	state.observe(now, throttle)
+	if got := state.interval(); got != time.Duration(backoffMaxMultiplier)*time.Second {
+		t.Errorf("interval at cap = %v, want %ds", got, backoffMaxMultiplier)
+	}
+
+	// exports inside the stretched window are skipped.
+	if !state.skip(now.Add(time.Second)) {
+		t.Error("export inside the stretched window not skipped")
+	}
+	if state.skip(now.Add(time.Duration(backoffMaxMultiplier) * time.Second)) {
+		t.Error("export after the stretched window skipped")
+	}
+
+	// successes shrink the interval back to the base.
+	for i := 0; i < 3; i++ {
+		state.observe(now, nil)
+	}
+	if got := state.interval(); got != time.Second {
+		t.Errorf("interval after recovery = %v, want 1s", got)
+	}
+	if state.skip(now) {
+		t.Error("export skipped after full recovery")
+	}
+}
+
+func TestBackoffMetricExporter(t *testing.T) {
+	ctx := context.Background()
+	mock := &throttlingMetricExporter{failures: 1}
+	state := &backoffState{base: time.Minute}
+	exporter := &backoffMetricExporter{Exporter: mock, state: state}
+
+	// the throttled attempt reaches the inner exporter and arms the
+	// backoff.
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); err == nil {
+		t.Fatal("Export() = nil, want throttling error")
+	}
+	if got := state.interval(); got != 2*time.Minute {
+		t.Errorf("interval after throttle = %v, want 2m", got)
+	}
+
+	// the next tick falls inside the stretched window and is dropped
+	// without touching the inner exporter.
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); err != nil {
+		t.Fatalf("Export() = %v, want nil for skipped tick", err)
+	}
+	if mock.calls != 1 {
+		t.Errorf("inner exporter called %d times, want 1", mock.calls)
+	}
+}
+
+func TestThrottledError(t *testing.T) {
+	if throttledError(nil) {
+		t.Error("nil classified as throttling")
+	}
+	if !throttledError(status.Error(codes.ResourceExhausted, "overload")) {
+		t.Error("ResourceExhausted not classified as throttling")
+	}
+	if !throttledError(errors.New("request throttled by upstream")) {
+		t.Error("throttle message not classified as throttling")
+	}
+	if throttledError(errors.New("connection refused")) {
+		t.Error("connection error classified as throttling")
+	}
+}
@@ -0,0 +1,62 @@
+package telemetry
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// registerBuildInfo registers the constant runner_build_info gauge,
+// valued 1 with version, commit and Go version attributes, so
+// dashboards can join other series on version. The caller must hold
+// t.mu and have a current meter provider.
+func (t *Telemetry) registerBuildInfo() error {
+	meter := t.meterProvider.Meter(instrumentationName)
+	version, commit := buildInfo()
+	gauge, err := meter.Int64ObservableGauge("runner_build_info",
+		metric.WithDescription("Constant gauge carrying the runner build metadata."),
+	)
+	if err != nil {
+		return err
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("version", version),
+		attribute.String("commit", commit),
+		attribute.String("go_version", runtime.Version()),
+	)
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(gauge, 1, attrs)
+		return nil
+	}, gauge)
+	return err
+}
+
+// Version returns the runner version embedded in the binary — the same
+// value stamped on the resource and the runner_build_info metric — or
+// "unknown" when the binary carries no build info.
+func Version() string {
+	version, _ := buildInfo()
+	return version
+}
+
+// buildInfo extracts the module version and VCS commit from the build
+// info embedded in the binary.
+func buildInfo() (version, commit string) {
+	version, commit = "unknown", "unknown"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version, commit
+	}
+	if info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			commit = setting.Value
+		}
+	}
+	return version, commit
+}
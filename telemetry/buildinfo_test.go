@@ -0,0 +1,66 @@
+package telemetry
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestBuildInfoGauge(t *testing.T) {
+	ctx := context.Background()
+	tel := New(Config{})
+	reader := sdkmetric.NewManualReader()
+	tel.meterProvider = sdkmetric.NewMeterProvider(tel.meterProviderOptions(reader)...)
+	defer tel.meterProvider.Shutdown(ctx) //nolint:errcheck
+
+	if err := tel.registerBuildInfo(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	var found *metricdata.Metrics
+	for i := range rm.ScopeMetrics {
+		for j := range rm.ScopeMetrics[i].Metrics {
+			if rm.ScopeMetrics[i].Metrics[j].Name == "runner_build_info" {
+				found = &rm.ScopeMetrics[i].Metrics[j]
+			}
+		}
+	}
+	if found == nil {
+		t.Fatal("runner_build_info gauge not collected")
+	}
+	gauge, ok := found.Data.(metricdata.Gauge[int64])
+	if !ok || len(gauge.DataPoints) != 1 {
+		t.Fatalf("unexpected gauge data: %+v", found.Data)
+	}
+	point := gauge.DataPoints[0]
+	if point.Value != 1 {
+		t.Errorf("runner_build_info value = %d, want 1", point.Value)
+	}
+	for _, key := range []string{"version", "commit", "go_version"} {
+		if _, ok := point.Attributes.Value(attribute.Key(key)); !ok {
+			t.Errorf("runner_build_info missing %q attribute", key)
+		}
+	}
+	if got, _ := point.Attributes.Value(attribute.Key("go_version")); got.AsString() != runtime.Version() {
+		t.Errorf("go_version = %q, want %q", got.AsString(), runtime.Version())
+	}
+}
+
+func TestVersion(t *testing.T) {
+	version := Version()
+	if version == "" {
+		t.Fatal("Version() is empty")
+	}
+	// the accessor reports the same version the gauge carries.
+	if wantVersion, _ := buildInfo(); version != wantVersion {
+		t.Errorf("Version() = %q, want %q", version, wantVersion)
+	}
+}
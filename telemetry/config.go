@@ -0,0 +1,915 @@
+package telemetry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Supported MetricTemporality values.
+const (
+	// TemporalityCumulative exports monotonically accumulating values,
+	// the OTLP default.
+	TemporalityCumulative = "cumulative"
+	// TemporalityDelta exports only the change since the previous
+	// collection for counters and histograms.
+	TemporalityDelta = "delta"
+)
+
+// Supported Compression values.
+const (
+	// CompressionNone sends OTLP payloads uncompressed, the default.
+	CompressionNone = "none"
+	// CompressionGzip gzip-compresses OTLP payloads, trading CPU for
+	// bandwidth on metered links to the collector.
+	CompressionGzip = "gzip"
+)
+
+// Supported InstrumentAggregation values.
+const (
+	// AggregationSum accumulates recorded values, the default for
+	// counters.
+	AggregationSum = "sum"
+	// AggregationLastValue keeps only the most recent recorded value,
+	// the default for gauges.
+	AggregationLastValue = "last-value"
+)
+
+// Supported LogBodyFormat values.
+const (
+	// LogBodyMessage sets the record body to the log message only.
+	LogBodyMessage = "message"
+	// LogBodyMessageWithFields folds the entry fields into the body as
+	// trailing key=value pairs, for backends that do not index
+	// attributes well.
+	LogBodyMessageWithFields = "message_with_fields"
+	// LogBodyJSON renders the message and fields as a JSON object.
+	LogBodyJSON = "json"
+)
+
+// Supported MetricsFormat values.
+const (
+	// MetricsFormatOTLP exports metrics over OTLP gRPC, the default.
+	MetricsFormatOTLP = "otlp"
+	// MetricsFormatPrometheusRemoteWrite pushes metrics to a Prometheus
+	// remote-write endpoint instead of an OTLP collector.
+	MetricsFormatPrometheusRemoteWrite = "prometheus-remote-write"
+)
+
+// Supported DuplicateAttributeStrategy values.
+const (
+	// DuplicateKeepBoth emits colliding entry and hook attributes side
+	// by side, the historical behavior and the default.
+	DuplicateKeepBoth = "keep-both"
+	// DuplicateEntryWins drops hook-derived attributes whose key is
+	// already present on the entry.
+	DuplicateEntryWins = "entry-wins"
+	// DuplicateContextWins drops entry attributes whose key is also
+	// derived by the hook.
+	DuplicateContextWins = "context-wins"
+)
+
+// Config configures the OpenTelemetry pipeline used to ship runner logs
+// and metrics to an OTLP collector over gRPC.
+type Config struct {
+	// Enabled gates the whole pipeline. Nil behaves as true. When
+	// disabled, Start is a no-op and NewResource skips the host and OS
+	// detectors, so a disabled configuration never pays for detection
+	// work.
+	Enabled *bool
+
+	// EnabledFunc, when set, is a dynamic enable flag consulted by a
+	// background poller every EnabledPollInterval. When the value it
+	// returns flips, export is paused or resumed accordingly, so a
+	// platform feature flag can gate telemetry at runtime without a
+	// pipeline rebuild. It does not affect whether Start builds the
+	// pipeline; combine with Enabled for a static kill switch.
+	EnabledFunc func() bool
+
+	// EnabledPollInterval controls how often EnabledFunc is consulted.
+	// Zero disables the poller.
+	EnabledPollInterval time.Duration
+
+	// Endpoint is the host:port of the OTLP gRPC collector.
+	Endpoint string
+
+	// Debug replaces the OTLP exporters with stdout exporters that
+	// pretty-print every record and metric to stderr, so local runs
+	// show their telemetry inline without a collector. No endpoint is
+	// required or dialed in debug mode.
+	Debug bool
+
+	// Insecure disables transport security on the collector connection.
+	Insecure bool
+
+	// TLSCACert is the path of a PEM certificate bundle that replaces
+	// the system trust store for the collector connection, for
+	// collectors behind a private CA. Empty keeps system trust. It has
+	// no effect on an insecure connection.
+	TLSCACert string
+
+	// TLSClientCert and TLSClientKey are the paths of a PEM client
+	// certificate and key pair presented to the collector for mutual
+	// TLS. Both must be set together.
+	TLSClientCert string
+	TLSClientKey  string
+
+	// LogsInsecure overrides Insecure for the logs exporter connection,
+	// for collectors that terminate TLS differently per signal. Nil
+	// falls back to Insecure.
+	LogsInsecure *bool
+
+	// MetricsInsecure overrides Insecure for the metrics exporter
+	// connection. Nil falls back to Insecure.
+	MetricsInsecure *bool
+
+	// TracesInsecure overrides Insecure for the trace exporter
+	// connection. Nil falls back to Insecure.
+	TracesInsecure *bool
+
+	// LogsEndpoint overrides Endpoint for the log exporter connection,
+	// for deployments that route each signal to a different collector.
+	// Empty falls back to Endpoint.
+	LogsEndpoint string
+
+	// MetricsEndpoint overrides Endpoint for the metric exporter
+	// connection. Empty falls back to Endpoint.
+	MetricsEndpoint string
+
+	// TracesEndpoint overrides Endpoint for the trace exporter
+	// connection. Empty falls back to Endpoint.
+	TracesEndpoint string
+
+	// ErrorLogsEndpoint, when set, is a second OTLP gRPC collector that
+	// error-and-above log records are routed to instead of Endpoint, so
+	// high-priority logs can take a different path than bulk output.
+	ErrorLogsEndpoint string
+
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string
+
+	// RequireServiceName makes Start fail when ServiceName is empty,
+	// for backends that reject telemetry without a service.name instead
+	// of silently accepting an empty one.
+	RequireServiceName bool
+
+	// Environment is reported as the deployment.environment.name
+	// resource attribute.
+	Environment string
+
+	// InstanceType, when set, is reported as the host.type resource
+	// attribute, for cost attribution by VM size. Runners that prefer
+	// detection over static configuration can supply a cloud detector
+	// through LazyResourceDetectors instead.
+	InstanceType string
+
+	// DetectEC2 queries the EC2 instance metadata service during
+	// resource construction, stamping cloud.provider, cloud.region,
+	// cloud.availability_zone, host.id, host.type, and host.image.id
+	// from the instance's own identity. It is off by default so runs
+	// outside EC2 do not pay the metadata timeout; when the service is
+	// unreachable a warning is logged and the base resource is kept.
+	DetectEC2 bool
+
+	// SchemaURL overrides the schema URL carried by the exported
+	// resource, for backends that validate telemetry against a specific
+	// OTEL schema version. Empty keeps the semconv default.
+	SchemaURL string
+
+	// Headers are static headers attached to every export request,
+	// typically used for collector authentication.
+	Headers map[string]string
+
+	// UserAgent overrides the user-agent reported to the collector on
+	// the export connections, for fleet identification. Empty defaults
+	// to drone-runner-aws/<version>.
+	UserAgent string
+
+	// HeadersFile, when set, points at a file of KEY=VALUE lines that is
+	// read at Start and merged over Headers. It allows collector
+	// credentials to be rotated on disk without restarting the runner.
+	HeadersFile string
+
+	// EmitHostShortname attaches the machine's non-FQDN short hostname
+	// as a host.shortname attribute on every log record. It is a coarse
+	// alternative for operators who disable host resource detection for
+	// privacy but still want to tell runners apart.
+	EmitHostShortname bool
+
+	// EmitActiveJobs attaches the runner's current job count (see
+	// SetActiveJobs) as a runner.active_jobs attribute on every log
+	// record, so log volume can be correlated with concurrent load.
+	EmitActiveJobs bool
+
+	// EmitTraceFlags attaches the span context's trace flags as a
+	// trace.flags attribute on log records emitted inside a span, so
+	// backends can tell sampled from unsampled correlated traces.
+	EmitTraceFlags bool
+
+	// EmitMessageBytes attaches the byte length of the log message as a
+	// log.message_bytes attribute on every record, so operators can
+	// histogram message sizes when hunting oversized log lines.
+	EmitMessageBytes bool
+
+	// MapHTTPSemconv renames well-known HTTP entry fields (method,
+	// status, path, latency_ms) to their OTEL semantic-convention
+	// equivalents on the exported record, so HTTP dashboards in the
+	// backend light up without a custom mapping.
+	MapHTTPSemconv bool
+
+	// SpanForErrorLogs wraps error-and-above entries that carry no
+	// active span in a short-lived span from the global tracer provider,
+	// so they still surface in the trace backend and the log record is
+	// emitted with the span's trace and span IDs.
+	SpanForErrorLogs bool
+
+	// SpanLevels, when non-empty, replaces the error-and-above default
+	// of SpanForErrorLogs with an explicit list of logrus level names
+	// (for example "warning") that trigger span creation. Unparseable
+	// names are ignored.
+	SpanLevels []string
+
+	// ElevateLogsWithError bumps the exported severity of entries that
+	// carry an error field to at least Warn, so an Info-level log with
+	// an attached error is not lost among routine output. The logrus
+	// level of the entry is untouched.
+	ElevateLogsWithError bool
+
+	// EmitInheritsDeadline controls whether the emit of a log record
+	// inherits the deadline of the entry's context. Nil behaves as
+	// true. Disabling it detaches the export from a possibly short
+	// request deadline, so a record is not canceled just because the
+	// request that logged it completed. The context's values are kept
+	// either way.
+	EmitInheritsDeadline *bool
+
+	// LogLevel, when set, is the minimum logrus level name (for example
+	// "info") the hook subscribes to, so trace and debug output stays
+	// out of the backend in production. Empty keeps every level.
+	LogLevel string
+
+	// LogSampleRatio, when in (0, 1), keeps roughly that fraction of
+	// log records and drops the rest before export. Contexts carrying a
+	// priority (see ContextWithLogPriority) scale their entry's keep
+	// probability. Zero disables sampling.
+	LogSampleRatio float64
+
+	// LogBodyFormat controls how the body of each log record is
+	// rendered. See the LogBody constants for the supported values. An
+	// empty or unknown value behaves as LogBodyMessage.
+	LogBodyFormat string
+
+	// JSONEncodeComplexFields marshals map and slice entry fields to
+	// compact JSON attribute values instead of Go's %v formatting, so
+	// backends can parse them. Values that cannot be marshaled fall back
+	// to %v.
+	JSONEncodeComplexFields bool
+
+	// MaxBodyLength, when positive, caps the record body at that many
+	// bytes before export, so a single stack dump cannot produce a
+	// megabyte record. Truncated records carry a body_truncated=true
+	// attribute. Attribute values are not affected.
+	MaxBodyLength int
+
+	// DuplicateAttributeStrategy controls what happens when an entry
+	// field collides with an attribute the hook derives itself (for
+	// example both carrying host.id). See the Duplicate constants for
+	// the supported values. Empty behaves as DuplicateKeepBoth.
+	DuplicateAttributeStrategy string
+
+	// MaxExportRetries, when positive, bounds how many times a single
+	// failed export is retried, regardless of elapsed time. It replaces
+	// the SDK's elapsed-time based retry policy. Zero keeps the SDK
+	// default.
+	MaxExportRetries int
+
+	// MaxConcurrentEmits, when positive, bounds how many hook Fire
+	// calls may be emitting simultaneously, protecting the synchronous
+	// processor from a goroutine spike when the exporter slows down. A
+	// Fire that cannot acquire a slot within EmitAcquireTimeout drops
+	// its record, counted on RecordsDropped. Zero leaves emits
+	// unbounded.
+	MaxConcurrentEmits int
+
+	// EmitAcquireTimeout is how long a saturated Fire waits for an
+	// emit slot before dropping the record. Zero drops immediately.
+	EmitAcquireTimeout time.Duration
+
+	// RetryEnabled overrides whether the SDK's built-in exporter retry
+	// is active. Nil keeps the SDK default (enabled). It is ignored
+	// when MaxExportRetries engages this package's own retry wrapper,
+	// which always disables the SDK's so the two never stack.
+	RetryEnabled *bool
+
+	// RetryInitialInterval, RetryMaxInterval and RetryMaxElapsedTime
+	// tune the SDK retry's backoff while it is active. Zero values
+	// keep the SDK defaults. Setting any of them implies RetryEnabled.
+	RetryInitialInterval time.Duration
+	RetryMaxInterval     time.Duration
+	RetryMaxElapsedTime  time.Duration
+
+	// FallbackLogFile, when set, is a local file that log records are
+	// spilled to while the collector is unreachable, so nothing is lost
+	// during an extended outage. The file rotates once it grows past an
+	// internal size cap and can be replayed later.
+	FallbackLogFile string
+
+	// PersistentQueueDir, when set, journals every log batch to a file
+	// in that directory before export and deletes it on success, so
+	// records survive a crash and are replayed on the next Start. It
+	// provides at-least-once delivery: a crash between export and
+	// journal deletion replays the batch a second time.
+	PersistentQueueDir string
+
+	// PostReconnectRampDelay, when positive, staggers the first few
+	// exports after the collector connection recovers from an outage,
+	// so the queued backlog does not overwhelm the collector. Zero
+	// resumes at full rate immediately.
+	PostReconnectRampDelay time.Duration
+
+	// AdaptiveExportBackoff stretches the effective metric export
+	// interval while the collector signals overload (throttling
+	// errors), and recovers it once exports succeed again. It has no
+	// effect with ManualMetricsExport, where the caller controls the
+	// cadence.
+	AdaptiveExportBackoff bool
+
+	// MinReconfigureInterval, when positive, is the minimum time between
+	// pipeline rebuilds. A Start or Reconfigure arriving sooner after
+	// the previous rebuild is skipped with a warning, so rapid config
+	// reloads do not thrash the collector connections.
+	MinReconfigureInterval time.Duration
+
+	// MetricsInitialDelay, when positive, forces a one-shot metrics
+	// collection that soon after Start, so jobs shorter than the
+	// steady-state export interval still export at least once. A zero
+	// delay keeps the periodic reader's normal schedule.
+	MetricsInitialDelay time.Duration
+
+	// ManualMetricsExport disables the periodic reader's timer
+	// entirely, for embedded use where the host controls flushing.
+	// Metrics are then only exported on explicit CollectMetrics calls.
+	ManualMetricsExport bool
+
+	// CollectOnShutdown controls whether the final metrics collection
+	// is exported during Shutdown. Nil behaves as true. Disabling it
+	// avoids noisy errors on short-lived processes whose collector
+	// connection is already down when they exit.
+	CollectOnShutdown *bool
+
+	// MetricsFormat selects the metrics export path. See the
+	// MetricsFormat constants for the supported values. Empty behaves
+	// as MetricsFormatOTLP.
+	MetricsFormat string
+
+	// RemoteWriteURL is the Prometheus remote-write endpoint metrics
+	// are pushed to when MetricsFormat is
+	// MetricsFormatPrometheusRemoteWrite.
+	RemoteWriteURL string
+
+	// MetricTemporality selects the aggregation temporality preference
+	// of the OTLP metrics reader. See the Temporality constants for the
+	// supported values. Empty behaves as TemporalityCumulative. The
+	// preference applies only to the OTLP reader; ExtraReaders carry
+	// their own selectors.
+	MetricTemporality string
+
+	// MetricsExportInterval overrides the steady-state export interval
+	// of the OTLP metrics reader, for runners whose lifetime is shorter
+	// than the 30s default. Zero keeps the default.
+	MetricsExportInterval time.Duration
+
+	// Compression selects the payload compression of the OTLP
+	// exporters. See the Compression constants for the supported
+	// values. Empty behaves as CompressionNone, so existing
+	// deployments keep sending uncompressed.
+	Compression string
+
+	// PrometheusGatherer, when set, bridges an existing client_golang
+	// registry into the OTLP metrics reader: every collection gathers
+	// it and exports the converted families alongside the SDK's own
+	// instruments. See NewPrometheusProducer for attaching a registry
+	// to a custom reader instead.
+	PrometheusGatherer prometheus.Gatherer
+
+	// ExtraReaders are additional metric readers registered on the
+	// meter provider alongside the OTLP periodic reader, for example a
+	// Prometheus bridge. Each reader keeps its own temporality
+	// selector.
+	ExtraReaders []sdkmetric.Reader
+
+	// MetricPrefix, when set, is prepended to every instrument name (for
+	// example "drone_"), so runner metrics do not collide with other
+	// producers in a shared backend. User MetricViews run first and see
+	// the unprefixed names.
+	MetricPrefix string
+
+	// MetricViews, when set, are applied to the meter provider in place
+	// of the default views, giving full control over aggregation and
+	// instrument renaming. Empty keeps the SDK defaults.
+	MetricViews []sdkmetric.View
+
+	// InstrumentAggregation overrides the aggregation of individual
+	// instruments by name in exported data, for values recorded through
+	// the wrong instrument kind — for example a gauge-like value pushed
+	// as a counter, which last-value ships as a gauge instead. See the
+	// Aggregation constants for the supported values.
+	InstrumentAggregation map[string]string
+
+	// DropEmptyMetrics removes instruments that collected no data
+	// points from each export cycle, so instruments that are created
+	// but never recorded do not appear as empty series in the backend.
+	DropEmptyMetrics bool
+
+	// MetricsDumpFile, when set, is a local file that histogram
+	// snapshots are appended to as JSON lines on every periodic
+	// collection, in addition to the OTLP export, for offline analysis.
+	MetricsDumpFile string
+
+	// DumpMetricsOnShutdown prints a final snapshot of every metric's
+	// closing value to stdout as one JSON document during Shutdown,
+	// independent of the OTLP export, so CI runs keep a record even
+	// when no collector was reachable.
+	DumpMetricsOnShutdown bool
+
+	// ExportTraces builds a tracer provider exporting spans over the
+	// collector connection alongside logs and metrics, and registers it
+	// as the global provider, so spans started through otel.Tracer —
+	// the hook's log spans included — reach the collector.
+	ExportTraces bool
+
+	// LazyInit defers building the exporters and dialing the collector
+	// from Start to the first emitted record, for rarely-active runners
+	// whose collector may not be ready at boot.
+	LazyInit bool
+
+	// InstallSignalHandler registers a SIGTERM/SIGINT handler during
+	// Start that flushes and shuts the pipeline down, for callers that
+	// do not wire Shutdown into their own signal handling. The handler
+	// is removed again when the pipeline shuts down through the regular
+	// path.
+	InstallSignalHandler bool
+
+	// AllowedEndpoints, when non-empty, restricts which collector hosts
+	// the pipeline may export to, so a misconfigured endpoint cannot leak
+	// telemetry outside the approved infrastructure. A pattern matches
+	// the endpoint host exactly, or any subdomain when prefixed with
+	// "*.". Both Endpoint and RemoteWriteURL are checked.
+	AllowedEndpoints []string
+
+	// ValidateEndpoint makes Start perform a single probe export and
+	// fail with a descriptive error when it does not succeed. Transport
+	// errors that look like a gRPC client reaching an HTTP OTLP port
+	// (4318) are annotated with a hint. Disabled by default because the
+	// probe requires the collector to be reachable at Start.
+	ValidateEndpoint bool
+
+	// MaxResourceAttributes, when positive, caps the number of resource
+	// attributes attached to exports, so chatty cloud detectors do not
+	// inflate every record. Priority keys (service.* and the deployment
+	// environment) are always kept, even past the cap. Zero disables
+	// the cap.
+	MaxResourceAttributes int
+
+	// LazyResourceDetectors are resource detectors run asynchronously
+	// after Start, for metadata that may not be available yet while the
+	// host is still booting (for example host.id from the cloud
+	// metadata service). Their attributes are merged into the resource
+	// once detection completes, so they appear on later exports without
+	// delaying startup.
+	LazyResourceDetectors []resource.Detector
+
+	// BaseResource, when set, is an existing resource the built one is
+	// merged over, for integrators that already carry their own OTEL
+	// resource. On conflicting keys this package's attributes win, so
+	// identity fields like service.name follow this configuration.
+	BaseResource *resource.Resource
+
+	// ConnectivityCallback, when set, is invoked with every connectivity
+	// state transition of the collector connection, so operators can
+	// alarm on connection flaps. The callback must not block.
+	ConnectivityCallback func(state connectivity.State)
+
+	// HeaderRefreshInterval controls how often HeadersFile is re-read
+	// while the pipeline is running. The exporters are rebuilt only when
+	// the parsed values differ from those currently in use, so an
+	// unchanged file never causes a reconnect. A zero interval disables
+	// the background refresh.
+	HeaderRefreshInterval time.Duration
+}
+
+// Clone returns a copy of the config whose reference fields are
+// detached from the original, so callers can derive a variant without
+// mutating shared state.
+func (c Config) Clone() Config {
+	clone := c
+	if c.Headers != nil {
+		clone.Headers = make(map[string]string, len(c.Headers))
+		for k, v := range c.Headers {
+			clone.Headers[k] = v
+		}
+	}
+	if c.MetricViews != nil {
+		clone.MetricViews = append([]sdkmetric.View(nil), c.MetricViews...)
+	}
+	if c.InstrumentAggregation != nil {
+		clone.InstrumentAggregation = make(map[string]string, len(c.InstrumentAggregation))
+		for k, v := range c.InstrumentAggregation {
+			clone.InstrumentAggregation[k] = v
+		}
+	}
+	if c.ExtraReaders != nil {
+		clone.ExtraReaders = append([]sdkmetric.Reader(nil), c.ExtraReaders...)
+	}
+	if c.LazyResourceDetectors != nil {
+		clone.LazyResourceDetectors = append([]resource.Detector(nil), c.LazyResourceDetectors...)
+	}
+	if c.AllowedEndpoints != nil {
+		clone.AllowedEndpoints = append([]string(nil), c.AllowedEndpoints...)
+	}
+	if c.SpanLevels != nil {
+		clone.SpanLevels = append([]string(nil), c.SpanLevels...)
+	}
+	return clone
+}
+
+// Merge returns a copy of the receiver with every non-zero field of
+// override applied on top, so a base configuration can be layered with
+// per-environment overrides. Headers merge key-by-key, with override
+// entries winning on collision; other reference fields replace the base
+// value wholesale. The result shares no reference fields with either
+// input.
+func (c Config) Merge(override Config) Config {
+	merged := c
+	ov := reflect.ValueOf(override)
+	mv := reflect.ValueOf(&merged).Elem()
+	for i := 0; i < ov.NumField(); i++ {
+		field := ov.Field(i)
+		if field.IsZero() || ov.Type().Field(i).Name == "Headers" {
+			continue
+		}
+		mv.Field(i).Set(field)
+	}
+	if len(c.Headers) > 0 || len(override.Headers) > 0 {
+		headers := make(map[string]string, len(c.Headers)+len(override.Headers))
+		for k, v := range c.Headers {
+			headers[k] = v
+		}
+		for k, v := range override.Headers {
+			headers[k] = v
+		}
+		merged.Headers = headers
+	}
+	return merged.Clone()
+}
+
+// ConfigFromEnv builds a Config from the DRONE_OTEL_* environment
+// variables, so deployments can configure telemetry without code
+// changes. Unset variables leave the corresponding field at its zero
+// value; the result is typically layered onto a base configuration with
+// Merge. Recognized variables: DRONE_OTEL_ENABLED,
+// DRONE_OTEL_ENDPOINT, DRONE_OTEL_INSECURE, DRONE_OTEL_EXPORT_TRACES,
+// DRONE_OTEL_SERVICE_NAME, DRONE_OTEL_ENVIRONMENT,
+// DRONE_OTEL_COMPRESSION, DRONE_OTEL_METRICS_INTERVAL (a duration such
+// as "10s") and DRONE_OTEL_HEADERS, the last a comma-separated list of
+// key=value pairs.
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		Endpoint:    os.Getenv("DRONE_OTEL_ENDPOINT"),
+		ServiceName: os.Getenv("DRONE_OTEL_SERVICE_NAME"),
+		Environment: os.Getenv("DRONE_OTEL_ENVIRONMENT"),
+		Compression: strings.ToLower(os.Getenv("DRONE_OTEL_COMPRESSION")),
+	}
+	if v := os.Getenv("DRONE_OTEL_ENABLED"); v != "" {
+		enabled, err := parseEnvBool("DRONE_OTEL_ENABLED", v)
+		if err != nil {
+			return Config{}, err
+		}
+		config.Enabled = &enabled
+	}
+	if v := os.Getenv("DRONE_OTEL_INSECURE"); v != "" {
+		insecure, err := parseEnvBool("DRONE_OTEL_INSECURE", v)
+		if err != nil {
+			return Config{}, err
+		}
+		config.Insecure = insecure
+	}
+	if v := os.Getenv("DRONE_OTEL_EXPORT_TRACES"); v != "" {
+		traces, err := parseEnvBool("DRONE_OTEL_EXPORT_TRACES", v)
+		if err != nil {
+			return Config{}, err
+		}
+		config.ExportTraces = traces
+	}
+	if v := os.Getenv("DRONE_OTEL_METRICS_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("telemetry: invalid duration DRONE_OTEL_METRICS_INTERVAL=%q", v)
+		}
+		config.MetricsExportInterval = interval
+	}
+	if v := os.Getenv("DRONE_OTEL_HEADERS"); v != "" {
+		headers := make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			key, value, ok := strings.Cut(pair, "=")
+			key = strings.TrimSpace(key)
+			if !ok || key == "" {
+				return Config{}, fmt.Errorf("telemetry: malformed DRONE_OTEL_HEADERS entry %q, want key=value", pair)
+			}
+			headers[key] = strings.TrimSpace(value)
+		}
+		config.Headers = headers
+	}
+	return config, nil
+}
+
+// parseEnvBool parses a boolean environment value, accepting the usual
+// spellings such as true/false and 1/0.
+func parseEnvBool(name, value string) (bool, error) {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("telemetry: invalid boolean %s=%q", name, value)
+	}
+	return parsed, nil
+}
+
+// Validate checks the configuration against constraints Start enforces,
+// without building anything. It verifies that an enabled configuration
+// names an endpoint, that header keys are non-empty, and that the
+// export destinations match AllowedEndpoints, so a typo surfaces as a
+// clear error instead of a cryptic exporter failure later.
+func (c Config) Validate() error {
+	if c.enabled() && c.Endpoint == "" && !c.Debug {
+		return fmt.Errorf("telemetry: Endpoint is required when telemetry is enabled")
+	}
+	for key := range c.Headers {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("telemetry: Headers contains an empty key")
+		}
+	}
+	switch c.Compression {
+	case "", CompressionNone, CompressionGzip:
+	default:
+		return fmt.Errorf("telemetry: unsupported Compression %q", c.Compression)
+	}
+	if c.LogLevel != "" {
+		if _, err := logrus.ParseLevel(c.LogLevel); err != nil {
+			return fmt.Errorf("telemetry: invalid LogLevel %q", c.LogLevel)
+		}
+	}
+	for name, agg := range c.InstrumentAggregation {
+		switch agg {
+		case AggregationSum, AggregationLastValue:
+		default:
+			return fmt.Errorf("telemetry: unsupported aggregation %q for instrument %q", agg, name)
+		}
+	}
+	if (c.TLSClientCert == "") != (c.TLSClientKey == "") {
+		return fmt.Errorf("telemetry: TLSClientCert and TLSClientKey must be set together")
+	}
+	if len(c.AllowedEndpoints) == 0 {
+		return nil
+	}
+	for _, endpoint := range []string{c.Endpoint, c.LogsEndpoint, c.MetricsEndpoint, c.TracesEndpoint, c.ErrorLogsEndpoint} {
+		if endpoint == "" {
+			continue
+		}
+		host := endpoint
+		if h, _, err := net.SplitHostPort(endpoint); err == nil {
+			host = h
+		}
+		if !hostAllowed(host, c.AllowedEndpoints) {
+			return fmt.Errorf("telemetry: endpoint %q does not match AllowedEndpoints", endpoint)
+		}
+	}
+	if c.RemoteWriteURL != "" {
+		u, err := url.Parse(c.RemoteWriteURL)
+		if err != nil {
+			return fmt.Errorf("telemetry: parse RemoteWriteURL: %w", err)
+		}
+		if !hostAllowed(u.Hostname(), c.AllowedEndpoints) {
+			return fmt.Errorf("telemetry: remote-write host %q does not match AllowedEndpoints", u.Hostname())
+		}
+	}
+	return nil
+}
+
+// hostAllowed reports whether the host matches any of the patterns. A
+// "*."-prefixed pattern matches any subdomain of its suffix; any other
+// pattern must match the host exactly.
+func hostAllowed(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled resolves whether the pipeline is enabled.
+func (c Config) enabled() bool {
+	if c.Enabled != nil {
+		return *c.Enabled
+	}
+	return true
+}
+
+// collectOnShutdown resolves whether the final shutdown collection is
+// exported.
+func (c Config) collectOnShutdown() bool {
+	if c.CollectOnShutdown != nil {
+		return *c.CollectOnShutdown
+	}
+	return true
+}
+
+// emitInheritsDeadline resolves whether emits inherit the entry
+// context's deadline.
+func (c Config) emitInheritsDeadline() bool {
+	if c.EmitInheritsDeadline != nil {
+		return *c.EmitInheritsDeadline
+	}
+	return true
+}
+
+// userAgent resolves the user-agent reported to the collector.
+func (c Config) userAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	version, _ := buildInfo()
+	return "drone-runner-aws/" + version
+}
+
+// exporterRetry is the resolved SDK retry policy applied to every OTLP
+// exporter. Each exporter package declares its own identical
+// RetryConfig type, so the shared resolution lives here and the call
+// sites translate it.
+type exporterRetry struct {
+	apply   bool
+	enabled bool
+	initial time.Duration
+	max     time.Duration
+	elapsed time.Duration
+}
+
+// exporterRetry resolves the SDK retry settings from the configuration.
+// With nothing configured no retry option is passed at all, keeping the
+// SDK default.
+func (c Config) exporterRetry() exporterRetry {
+	// the SDK's own defaults, restated because passing a RetryConfig
+	// replaces them wholesale.
+	r := exporterRetry{
+		initial: 5 * time.Second,
+		max:     30 * time.Second,
+		elapsed: time.Minute,
+	}
+	if c.RetryInitialInterval > 0 {
+		r.initial = c.RetryInitialInterval
+	}
+	if c.RetryMaxInterval > 0 {
+		r.max = c.RetryMaxInterval
+	}
+	if c.RetryMaxElapsedTime > 0 {
+		r.elapsed = c.RetryMaxElapsedTime
+	}
+	switch {
+	case c.MaxExportRetries > 0:
+		r.apply, r.enabled = true, false
+	case c.RetryEnabled != nil:
+		r.apply, r.enabled = true, *c.RetryEnabled
+	case c.RetryInitialInterval > 0 || c.RetryMaxInterval > 0 || c.RetryMaxElapsedTime > 0:
+		r.apply, r.enabled = true, true
+	}
+	return r
+}
+
+// metricsInterval resolves the effective metric export interval,
+// falling back to the package default when no override is configured.
+func (c Config) metricsInterval() time.Duration {
+	if c.MetricsExportInterval > 0 {
+		return c.MetricsExportInterval
+	}
+	return metricInterval
+}
+
+// configHash returns a stable hash of the effective configuration, for
+// detecting config drift across a fleet. Scalar fields, string slices,
+// and string maps contribute in declaration order; header values are
+// replaced by their keys so secrets never reach the hash; functions,
+// detectors, and other runtime-only fields are skipped because they
+// have no stable representation.
+func (c Config) configHash() string {
+	digest := sha256.New()
+	value := reflect.ValueOf(c)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		name := value.Type().Field(i).Name
+		switch field.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+			fmt.Fprintf(digest, "%s=%v;", name, field.Interface())
+		case reflect.Ptr:
+			if field.Type().Elem().Kind() == reflect.Bool {
+				if field.IsNil() {
+					fmt.Fprintf(digest, "%s=nil;", name)
+				} else {
+					fmt.Fprintf(digest, "%s=%v;", name, field.Elem().Interface())
+				}
+			}
+		case reflect.Slice:
+			if field.Type().Elem().Kind() == reflect.String {
+				fmt.Fprintf(digest, "%s=%v;", name, field.Interface())
+			}
+		case reflect.Map:
+			if m, ok := field.Interface().(map[string]string); ok {
+				keys := make([]string, 0, len(m))
+				for k := range m {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					if name == "Headers" {
+						fmt.Fprintf(digest, "%s[%s];", name, k)
+						continue
+					}
+					fmt.Fprintf(digest, "%s[%s]=%s;", name, k, m[k])
+				}
+			}
+		}
+	}
+	return hex.EncodeToString(digest.Sum(nil))[:16]
+}
+
+// logsEndpoint resolves the effective collector endpoint for the log
+// exporter connection.
+func (c Config) logsEndpoint() string {
+	if c.LogsEndpoint != "" {
+		return c.LogsEndpoint
+	}
+	return c.Endpoint
+}
+
+// metricsEndpoint resolves the effective collector endpoint for the
+// metric exporter connection.
+func (c Config) metricsEndpoint() string {
+	if c.MetricsEndpoint != "" {
+		return c.MetricsEndpoint
+	}
+	return c.Endpoint
+}
+
+// tracesEndpoint resolves the effective collector endpoint for the
+// trace exporter connection.
+func (c Config) tracesEndpoint() string {
+	if c.TracesEndpoint != "" {
+		return c.TracesEndpoint
+	}
+	return c.Endpoint
+}
+
+// logsInsecure resolves the effective transport security setting for
+// the logs exporter connection.
+func (c Config) logsInsecure() bool {
+	if c.LogsInsecure != nil {
+		return *c.LogsInsecure
+	}
+	return c.Insecure
+}
+
+// metricsInsecure resolves the effective transport security setting for
+// the metrics exporter connection.
+func (c Config) metricsInsecure() bool {
+	if c.MetricsInsecure != nil {
+		return *c.MetricsInsecure
+	}
+	return c.Insecure
+}
+
+// tracesInsecure resolves the effective transport security setting for
+// the trace exporter connection.
+func (c Config) tracesInsecure() bool {
+	if c.TracesInsecure != nil {
+		return *c.TracesInsecure
+	}
+	return c.Insecure
+}
@@ -0,0 +1,624 @@
+package telemetry
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestPerSignalInsecure(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		wantLogs    bool
+		wantMetrics bool
+		wantTraces  bool
+	}{
+		{
+			name:        "global only",
+			config:      Config{Insecure: true},
+			wantLogs:    true,
+			wantMetrics: true,
+			wantTraces:  true,
+		},
+		{
+			name:        "logs override",
+			config:      Config{Insecure: false, LogsInsecure: boolPtr(true)},
+			wantLogs:    true,
+			wantMetrics: false,
+			wantTraces:  false,
+		},
+		{
+			name:        "metrics override",
+			config:      Config{Insecure: true, MetricsInsecure: boolPtr(false)},
+			wantLogs:    true,
+			wantMetrics: false,
+			wantTraces:  true,
+		},
+		{
+			name:        "traces override",
+			config:      Config{Insecure: false, TracesInsecure: boolPtr(true)},
+			wantLogs:    false,
+			wantMetrics: false,
+			wantTraces:  true,
+		},
+		{
+			name:        "all overridden",
+			config:      Config{LogsInsecure: boolPtr(true), MetricsInsecure: boolPtr(true), TracesInsecure: boolPtr(true)},
+			wantLogs:    true,
+			wantMetrics: true,
+			wantTraces:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.logsInsecure(); got != tt.wantLogs {
+				t.Errorf("logsInsecure() = %v, want %v", got, tt.wantLogs)
+			}
+			if got := tt.config.metricsInsecure(); got != tt.wantMetrics {
+				t.Errorf("metricsInsecure() = %v, want %v", got, tt.wantMetrics)
+			}
+			if got := tt.config.tracesInsecure(); got != tt.wantTraces {
+				t.Errorf("tracesInsecure() = %v, want %v", got, tt.wantTraces)
+			}
+		})
+	}
+}
+
+func TestConfigClone(t *testing.T) {
+	original := Config{
+		Endpoint: "127.0.0.1:4317",
+		Headers:  map[string]string{"Authorization": "Bearer one"},
+	}
+	clone := original.Clone()
+	clone.Endpoint = "other:4317"
+	clone.Headers["Authorization"] = "Bearer two"
+
+	if original.Endpoint != "127.0.0.1:4317" {
+		t.Errorf("original Endpoint mutated to %q", original.Endpoint)
+	}
+	if original.Headers["Authorization"] != "Bearer one" {
+		t.Errorf("original Headers mutated to %q", original.Headers["Authorization"])
+	}
+}
+
+func TestConfigMerge(t *testing.T) {
+	base := Config{
+		Endpoint:    "otel.example.com:4317",
+		ServiceName: "drone-runner-aws",
+		Environment: "staging",
+		Insecure:    true,
+		Headers:     map[string]string{"Authorization": "Bearer base", "X-Tenant": "acme"},
+	}
+	override := Config{
+		Environment:       "prod",
+		Enabled:           boolPtr(false),
+		Headers:           map[string]string{"Authorization": "Bearer prod"},
+		MaxExportRetries:  3,
+		CollectOnShutdown: boolPtr(false),
+	}
+
+	merged := base.Merge(override)
+
+	// zero override fields keep the base values.
+	if merged.Endpoint != "otel.example.com:4317" {
+		t.Errorf("Endpoint = %q, want base value", merged.Endpoint)
+	}
+	if merged.ServiceName != "drone-runner-aws" {
+		t.Errorf("ServiceName = %q, want base value", merged.ServiceName)
+	}
+	if !merged.Insecure {
+		t.Error("Insecure lost by merge")
+	}
+
+	// non-zero override fields win.
+	if merged.Environment != "prod" {
+		t.Errorf("Environment = %q, want %q", merged.Environment, "prod")
+	}
+	if merged.MaxExportRetries != 3 {
+		t.Errorf("MaxExportRetries = %d, want 3", merged.MaxExportRetries)
+	}
+	if merged.Enabled == nil || *merged.Enabled {
+		t.Error("Enabled override not applied")
+	}
+	if merged.CollectOnShutdown == nil || *merged.CollectOnShutdown {
+		t.Error("CollectOnShutdown override not applied")
+	}
+
+	// headers merge key-by-key rather than replacing the map.
+	if got := merged.Headers["Authorization"]; got != "Bearer prod" {
+		t.Errorf("Authorization = %q, want override value", got)
+	}
+	if got := merged.Headers["X-Tenant"]; got != "acme" {
+		t.Errorf("X-Tenant = %q, want base value preserved", got)
+	}
+
+	// the result is detached from both inputs.
+	merged.Headers["Authorization"] = "mutated"
+	if base.Headers["Authorization"] != "Bearer base" || override.Headers["Authorization"] != "Bearer prod" {
+		t.Error("Merge result shares the headers map with an input")
+	}
+}
+
+func TestActiveConfigIsSnapshot(t *testing.T) {
+	tel := New(Config{
+		Endpoint: "127.0.0.1:4317",
+		Headers:  map[string]string{"x-token": "one"},
+	})
+	snapshot := tel.ActiveConfig()
+	snapshot.Endpoint = "mutated:4317"
+	snapshot.Headers["x-token"] = "mutated"
+
+	if got := tel.ActiveConfig().Endpoint; got != "127.0.0.1:4317" {
+		t.Errorf("internal Endpoint = %q after mutating snapshot", got)
+	}
+	if got := tel.ActiveConfig().Headers["x-token"]; got != "one" {
+		t.Errorf("internal header = %q after mutating snapshot", got)
+	}
+}
+
+func TestAllowedEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{
+			name:   "empty allowlist permits anything",
+			config: Config{Endpoint: "anywhere.example.net:4317"},
+		},
+		{
+			name: "exact host allowed",
+			config: Config{
+				Endpoint:         "otel.example.com:4317",
+				AllowedEndpoints: []string{"otel.example.com"},
+			},
+		},
+		{
+			name: "wildcard subdomain allowed",
+			config: Config{
+				Endpoint:         "otel.us-east-1.example.com:4317",
+				AllowedEndpoints: []string{"*.example.com"},
+			},
+		},
+		{
+			name: "host not in allowlist rejected",
+			config: Config{
+				Endpoint:         "attacker.example.net:4317",
+				AllowedEndpoints: []string{"otel.example.com", "*.example.org"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcard does not match bare suffix owner lookalike",
+			config: Config{
+				Endpoint:         "notexample.com:4317",
+				AllowedEndpoints: []string{"*.example.com"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error-logs host checked too",
+			config: Config{
+				Endpoint:          "otel.example.com:4317",
+				ErrorLogsEndpoint: "errors.example.net:4317",
+				AllowedEndpoints:  []string{"otel.example.com"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "remote-write host checked too",
+			config: Config{
+				Endpoint:         "otel.example.com:4317",
+				RemoteWriteURL:   "https://prom.example.net/api/v1/write",
+				AllowedEndpoints: []string{"otel.example.com"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Start enforces the same check.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tel := New(Config{
+		Endpoint:         "127.0.0.1:4317",
+		Insecure:         true,
+		ServiceName:      "drone-runner-aws",
+		AllowedEndpoints: []string{"otel.example.com"},
+	})
+	if err := tel.Start(ctx); err == nil {
+		t.Error("Start() = nil, want error for disallowed endpoint")
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}
+}
+
+func TestRequireServiceName(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// required mode rejects an empty service name.
+	tel := New(Config{
+		Endpoint:           "127.0.0.1:4317",
+		Insecure:           true,
+		RequireServiceName: true,
+	})
+	if err := tel.Start(ctx); err == nil {
+		t.Error("Start() = nil, want error for empty ServiceName")
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}
+
+	// optional mode keeps the current lenient behavior.
+	tel = New(Config{
+		Endpoint: "127.0.0.1:4317",
+		Insecure: true,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Errorf("Start() = %v, want nil without RequireServiceName", err)
+	}
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+}
+
+func TestPerSignalInsecureConnections(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// differing per-signal settings require separate connections.
+	tel := New(Config{
+		Endpoint:     "127.0.0.1:4317",
+		ServiceName:  "drone-runner-aws",
+		LogsInsecure: boolPtr(true),
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(tel.conns); got != 2 {
+		t.Errorf("connection count = %d, want 2", got)
+	}
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+
+	// matching settings share one connection.
+	tel = New(Config{
+		Endpoint:    "127.0.0.1:4317",
+		ServiceName: "drone-runner-aws",
+		Insecure:    true,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(tel.conns); got != 1 {
+		t.Errorf("connection count = %d, want 1", got)
+	}
+	tel.Shutdown(shutCtx) //nolint:errcheck
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("DRONE_OTEL_ENABLED", "1")
+	t.Setenv("DRONE_OTEL_ENDPOINT", "collector.internal:4317")
+	t.Setenv("DRONE_OTEL_INSECURE", "true")
+	t.Setenv("DRONE_OTEL_EXPORT_TRACES", "0")
+	t.Setenv("DRONE_OTEL_SERVICE_NAME", "drone-runner-aws")
+	t.Setenv("DRONE_OTEL_ENVIRONMENT", "staging")
+	t.Setenv("DRONE_OTEL_HEADERS", "Authorization=Bearer token, X-Tenant=acme")
+
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv() error = %v", err)
+	}
+	if config.Enabled == nil || !*config.Enabled {
+		t.Errorf("Enabled = %v, want true", config.Enabled)
+	}
+	if config.Endpoint != "collector.internal:4317" {
+		t.Errorf("Endpoint = %q", config.Endpoint)
+	}
+	if !config.Insecure {
+		t.Error("Insecure = false, want true")
+	}
+	if config.ExportTraces {
+		t.Error("ExportTraces = true, want false")
+	}
+	if config.ServiceName != "drone-runner-aws" {
+		t.Errorf("ServiceName = %q", config.ServiceName)
+	}
+	if config.Environment != "staging" {
+		t.Errorf("Environment = %q", config.Environment)
+	}
+	if got := config.Headers["Authorization"]; got != "Bearer token" {
+		t.Errorf("Headers[Authorization] = %q", got)
+	}
+	if got := config.Headers["X-Tenant"]; got != "acme" {
+		t.Errorf("Headers[X-Tenant] = %q", got)
+	}
+}
+
+func TestConfigFromEnvDefaults(t *testing.T) {
+	for _, name := range []string{
+		"DRONE_OTEL_ENABLED", "DRONE_OTEL_ENDPOINT", "DRONE_OTEL_INSECURE",
+		"DRONE_OTEL_EXPORT_TRACES", "DRONE_OTEL_SERVICE_NAME",
+		"DRONE_OTEL_ENVIRONMENT", "DRONE_OTEL_HEADERS",
+	} {
+		t.Setenv(name, "")
+	}
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv() error = %v", err)
+	}
+	if config.Enabled != nil {
+		t.Errorf("Enabled = %v, want nil", config.Enabled)
+	}
+	if config.Headers != nil {
+		t.Errorf("Headers = %v, want nil", config.Headers)
+	}
+}
+
+func TestConfigFromEnvErrors(t *testing.T) {
+	t.Run("bad boolean", func(t *testing.T) {
+		t.Setenv("DRONE_OTEL_ENABLED", "yes")
+		if _, err := ConfigFromEnv(); err == nil {
+			t.Fatal("expected error for DRONE_OTEL_ENABLED=yes")
+		}
+	})
+	t.Run("malformed header", func(t *testing.T) {
+		t.Setenv("DRONE_OTEL_HEADERS", "Authorization=ok,broken")
+		_, err := ConfigFromEnv()
+		if err == nil {
+			t.Fatal("expected error for malformed header entry")
+		}
+		if want := `"broken"`; !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not name the bad entry", err)
+		}
+	})
+}
+
+func TestValidateMisconfiguration(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{
+			name:    "enabled without endpoint",
+			config:  Config{},
+			wantErr: "Endpoint is required",
+		},
+		{
+			name:   "disabled without endpoint",
+			config: Config{Enabled: boolPtr(false)},
+		},
+		{
+			name: "empty header key",
+			config: Config{
+				Endpoint: "127.0.0.1:4317",
+				Headers:  map[string]string{" ": "value"},
+			},
+			wantErr: "empty key",
+		},
+		{
+			name: "valid",
+			config: Config{
+				Endpoint: "127.0.0.1:4317",
+				Headers:  map[string]string{"Authorization": "Bearer token"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompression(t *testing.T) {
+	// an unknown value is caught by validation before anything dials.
+	config := Config{Endpoint: "127.0.0.1:4317", Compression: "brotli"}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "Compression") {
+		t.Errorf("Validate() = %v, want unsupported-compression error", err)
+	}
+
+	// gzip builds the exporters; the compressor only engages on the
+	// wire, so Start succeeds without a live collector.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tel := New(Config{
+		Endpoint:     "127.0.0.1:4317",
+		Insecure:     true,
+		ServiceName:  "drone-runner-aws",
+		ExportTraces: true,
+		Compression:  CompressionGzip,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatalf("Start() = %v with gzip compression", err)
+	}
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+}
+
+func TestConfigFromEnvMetricsInterval(t *testing.T) {
+	t.Setenv("DRONE_OTEL_METRICS_INTERVAL", "10s")
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv() error = %v", err)
+	}
+	if config.MetricsExportInterval != 10*time.Second {
+		t.Errorf("MetricsExportInterval = %v, want 10s", config.MetricsExportInterval)
+	}
+
+	t.Setenv("DRONE_OTEL_METRICS_INTERVAL", "soon")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("expected error for DRONE_OTEL_METRICS_INTERVAL=soon")
+	}
+}
+
+func TestExporterRetry(t *testing.T) {
+	// nothing configured: no retry option is passed at all.
+	if r := (Config{}).exporterRetry(); r.apply {
+		t.Errorf("exporterRetry() = %+v, want apply=false by default", r)
+	}
+
+	// the package's own retry wrapper disables the SDK's.
+	if r := (Config{MaxExportRetries: 3}).exporterRetry(); !r.apply || r.enabled {
+		t.Errorf("exporterRetry() = %+v, want applied and disabled with MaxExportRetries", r)
+	}
+
+	// an explicit off switch.
+	if r := (Config{RetryEnabled: boolPtr(false)}).exporterRetry(); !r.apply || r.enabled {
+		t.Errorf("exporterRetry() = %+v, want applied and disabled", r)
+	}
+
+	// custom backoff implies an enabled retry; unset knobs keep the
+	// SDK defaults.
+	r := Config{RetryInitialInterval: time.Second, RetryMaxElapsedTime: 2 * time.Minute}.exporterRetry()
+	if !r.apply || !r.enabled {
+		t.Fatalf("exporterRetry() = %+v, want applied and enabled", r)
+	}
+	if r.initial != time.Second || r.max != 30*time.Second || r.elapsed != 2*time.Minute {
+		t.Errorf("exporterRetry() backoff = %+v", r)
+	}
+
+	// a custom retry policy builds real exporters for every signal.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tel := New(Config{
+		Endpoint:             "127.0.0.1:4317",
+		Insecure:             true,
+		ServiceName:          "drone-runner-aws",
+		ExportTraces:         true,
+		RetryEnabled:         boolPtr(true),
+		RetryInitialInterval: time.Second,
+		RetryMaxInterval:     5 * time.Second,
+		RetryMaxElapsedTime:  30 * time.Second,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatalf("Start() = %v with custom retry policy", err)
+	}
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+}
+
+func TestPerSignalEndpoints(t *testing.T) {
+	// each per-signal endpoint falls back to Endpoint when empty.
+	config := Config{Endpoint: "shared:4317"}
+	if got := config.logsEndpoint(); got != "shared:4317" {
+		t.Errorf("logsEndpoint() = %q, want the shared endpoint", got)
+	}
+	if got := config.metricsEndpoint(); got != "shared:4317" {
+		t.Errorf("metricsEndpoint() = %q, want the shared endpoint", got)
+	}
+	if got := config.tracesEndpoint(); got != "shared:4317" {
+		t.Errorf("tracesEndpoint() = %q, want the shared endpoint", got)
+	}
+
+	config = Config{
+		Endpoint:        "shared:4317",
+		LogsEndpoint:    "logs:4317",
+		MetricsEndpoint: "metrics:4317",
+		TracesEndpoint:  "traces:4317",
+	}
+	if got := config.logsEndpoint(); got != "logs:4317" {
+		t.Errorf("logsEndpoint() = %q, want the override", got)
+	}
+	if got := config.metricsEndpoint(); got != "metrics:4317" {
+		t.Errorf("metricsEndpoint() = %q, want the override", got)
+	}
+	if got := config.tracesEndpoint(); got != "traces:4317" {
+		t.Errorf("tracesEndpoint() = %q, want the override", got)
+	}
+
+	// the allowlist covers per-signal endpoints too.
+	config.AllowedEndpoints = []string{"shared", "logs", "traces"}
+	if err := config.Validate(); err == nil {
+		t.Error("Validate() = nil with a metrics endpoint outside AllowedEndpoints")
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	base := Config{
+		Endpoint:    "collector:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+		Headers:     map[string]string{"Authorization": "Bearer secret-one"},
+	}
+	if got, want := base.configHash(), base.configHash(); got != want {
+		t.Errorf("configHash() not deterministic: %s vs %s", got, want)
+	}
+	same := base.Clone()
+	if base.configHash() != same.configHash() {
+		t.Error("identical configs hash differently")
+	}
+
+	// header values are masked: a rotated secret keeps the hash stable,
+	// while a different header key changes it.
+	rotated := base.Clone()
+	rotated.Headers["Authorization"] = "Bearer secret-two"
+	if base.configHash() != rotated.configHash() {
+		t.Error("rotated header value changed the hash")
+	}
+	rekeyed := base.Clone()
+	rekeyed.Headers = map[string]string{"X-Scope-OrgID": "tenant"}
+	if base.configHash() == rekeyed.configHash() {
+		t.Error("different header key did not change the hash")
+	}
+
+	changed := base.Clone()
+	changed.Endpoint = "other:4317"
+	if base.configHash() == changed.configHash() {
+		t.Error("changed endpoint did not change the hash")
+	}
+}
+
+func TestConfigHashResourceAttribute(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	config := Config{
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+	}
+	tel := New(config)
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}()
+
+	value, ok := tel.res.Set().Value("runner.config_hash")
+	if !ok {
+		t.Fatal("runner.config_hash missing from the resource")
+	}
+	if value.AsString() != config.configHash() {
+		t.Errorf("runner.config_hash = %s, want %s", value.AsString(), config.configHash())
+	}
+}
@@ -0,0 +1,128 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// dialCollector creates a gRPC connection to the given collector
+// endpoint with the given transport security setting. The caller must
+// hold t.mu.
+func (t *Telemetry) dialCollector(endpoint string, insecureConn bool) (*grpc.ClientConn, error) {
+	var creds credentials.TransportCredentials
+	if insecureConn {
+		creds = insecure.NewCredentials()
+	} else {
+		tlsConfig, err := buildTLSConfig(t.config)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	return grpc.NewClient(endpoint,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(t.headerSource.unaryInterceptor),
+		grpc.WithPerRPCCredentials(perRPCMetadata{}),
+		grpc.WithUserAgent(t.config.userAgent()),
+	)
+}
+
+// buildTLSConfig assembles the TLS configuration of the collector
+// connection. With no certificate paths set it is the system-trust
+// default; TLSCACert replaces the root pool with the private CA, and
+// TLSClientCert/TLSClientKey add the client pair for mutual TLS.
+func buildTLSConfig(config Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSCACert != "" {
+		pemData, err := os.ReadFile(config.TLSCACert)
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("telemetry: no certificates found in %s", config.TLSCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.TLSClientCert != "" || config.TLSClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(config.TLSClientCert, config.TLSClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// watchConnectivity launches a watcher per collector connection that
+// observes connectivity state transitions, counts them on a metric,
+// and invokes the configured callback. The caller must hold t.mu and
+// have a current meter provider.
+func (t *Telemetry) watchConnectivity(conns []*grpc.ClientConn) {
+	counter, err := t.meterProvider.Meter(instrumentationName).Int64Counter(
+		"otel.collector.connection.transitions",
+		metric.WithDescription("Connectivity state transitions of the collector connection."),
+	)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+
+	watchCtx, cancel := context.WithCancel(t.bgCtx)
+	if t.connCancel != nil {
+		t.connCancel()
+	}
+	t.connCancel = cancel
+
+	ramp := t.ramp
+	callback := t.config.ConnectivityCallback
+	for _, conn := range conns {
+		t.wg.Add(1)
+		go func(conn *grpc.ClientConn) {
+			defer t.wg.Done()
+			conn.Connect()
+			state := conn.GetState()
+			for {
+				counter.Add(watchCtx, 1,
+					metric.WithAttributes(attribute.String("state", state.String())))
+				if callback != nil {
+					callback(state)
+				}
+				previous := state
+				if !conn.WaitForStateChange(watchCtx, state) {
+					return
+				}
+				state = conn.GetState()
+				// kick idle connections so outages surface as transient
+				// failures instead of an indefinite idle.
+				if state == connectivity.Idle {
+					conn.Connect()
+				}
+				// a recovery from an outage arms the export ramp.
+				if ramp != nil && state == connectivity.Ready && previous == connectivity.TransientFailure {
+					ramp.trip()
+				}
+				// while the connection recovers, only error-and-above
+				// records are emitted; a Ready state restores the
+				// configured behavior.
+				switch state {
+				case connectivity.TransientFailure:
+					t.degraded.Store(true)
+				case connectivity.Ready:
+					t.degraded.Store(false)
+				}
+			}
+		}(conn)
+	}
+}
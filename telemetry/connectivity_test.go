@@ -0,0 +1,307 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestConnectivityStates(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	go srv.Serve(lis) //nolint:errcheck
+	defer srv.Stop()
+
+	states := make(chan connectivity.State, 16)
+	tel := New(Config{
+		Endpoint:    lis.Addr().String(),
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+		ConnectivityCallback: func(s connectivity.State) {
+			states <- s
+		},
+	})
+	if err := tel.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		tel.Shutdown(ctx) //nolint:errcheck
+	}()
+
+	// the watcher kicks the lazy connection, so it must reach Ready
+	// while the listener is up.
+	waitForState(t, states, func(s connectivity.State) bool {
+		return s == connectivity.Ready
+	}, "Ready")
+
+	// stopping the server must surface a transition away from Ready.
+	srv.Stop()
+	waitForState(t, states, func(s connectivity.State) bool {
+		return s != connectivity.Ready
+	}, "non-Ready")
+
+	// once the outage surfaces as a transient failure, the pipeline
+	// enters degraded mode.
+	waitForState(t, states, func(s connectivity.State) bool {
+		return s == connectivity.TransientFailure
+	}, "TransientFailure")
+	deadline := time.Now().Add(5 * time.Second)
+	for !tel.degraded.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("degraded mode not entered on transient failure")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	// the default carries the runner name and version.
+	if got := (Config{}).userAgent(); !strings.HasPrefix(got, "drone-runner-aws/") {
+		t.Errorf("default userAgent() = %q, want drone-runner-aws/<version>", got)
+	}
+	if got := (Config{UserAgent: "fleet-7"}).userAgent(); got != "fleet-7" {
+		t.Errorf("userAgent() = %q, want %q", got, "fleet-7")
+	}
+
+	// the configured value is reported on export RPCs.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	captured := make(chan metadata.MD, 4)
+	srv := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(srv, &captureLogsServer{md: captured})
+	go srv.Serve(lis) //nolint:errcheck
+	defer srv.Stop()
+
+	tel := New(Config{
+		Endpoint:    lis.Addr().String(),
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+		UserAgent:   "fleet-7",
+	})
+	if err := tel.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		tel.Shutdown(ctx) //nolint:errcheck
+	}()
+
+	entry := &logrus.Entry{Time: time.Now(), Level: logrus.InfoLevel, Message: "ua check", Data: logrus.Fields{}}
+	if err := tel.hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case md := <-captured:
+		if got := md.Get("user-agent"); len(got) != 1 || !strings.HasPrefix(got[0], "fleet-7") {
+			t.Errorf("user-agent = %v, want prefix fleet-7", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no export received")
+	}
+}
+
+func waitForState(t *testing.T, states <-chan connectivity.State, match func(connectivity.State) bool, want string) {
+	t.Helper()
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case s := <-states:
+			if match(s) {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("connection never reached %s state", want)
+		}
+	}
+}
+
+// writeTestCA writes a fresh self-signed certificate and key pair into
+// dir and returns their paths.
+func writeTestCA(t *testing.T, dir string) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "telemetry-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath = filepath.Join(dir, "ca.pem")
+	keyPath = filepath.Join(dir, "ca-key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certPath, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeTestCA(t, dir)
+
+	// no paths keeps the system-trust default.
+	tlsConfig, err := buildTLSConfig(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.RootCAs != nil || len(tlsConfig.Certificates) != 0 {
+		t.Error("empty config did not produce the system-trust default")
+	}
+
+	// a private CA replaces the root pool and the client pair loads.
+	tlsConfig, err = buildTLSConfig(Config{
+		TLSCACert:     certPath,
+		TLSClientCert: certPath,
+		TLSClientKey:  keyPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.RootCAs == nil {
+		t.Error("CA file did not populate the root pool")
+	}
+	if len(tlsConfig.Certificates) != 1 {
+		t.Errorf("client certificates = %d, want 1", len(tlsConfig.Certificates))
+	}
+
+	// unreadable and malformed files produce clear errors.
+	if _, err := buildTLSConfig(Config{TLSCACert: filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Error("expected error for a missing CA file")
+	}
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buildTLSConfig(Config{TLSCACert: garbage}); err == nil {
+		t.Error("expected error for a malformed CA file")
+	}
+
+	// a client cert without its key is caught by validation.
+	config := Config{Endpoint: "127.0.0.1:4317", TLSClientCert: certPath}
+	if err := config.Validate(); err == nil {
+		t.Error("Validate() = nil, want error for client cert without key")
+	}
+}
+
+func TestConnectionSharing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// logs, metrics, traces, and error logs all target the same
+	// collector: one connection serves every signal.
+	tel := New(Config{
+		Endpoint:          "127.0.0.1:4317",
+		Insecure:          true,
+		ServiceName:       "drone-runner-aws",
+		ExportTraces:      true,
+		ErrorLogsEndpoint: "127.0.0.1:4317",
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(tel.conns); got != 1 {
+		t.Errorf("connections to a single collector = %d, want 1 shared", got)
+	}
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	tel.Shutdown(shutCtx) //nolint:errcheck
+	shutCancel()
+
+	// a distinct error-logs collector still gets its own connection.
+	tel = New(Config{
+		Endpoint:          "127.0.0.1:4317",
+		Insecure:          true,
+		ServiceName:       "drone-runner-aws",
+		ErrorLogsEndpoint: "127.0.0.1:4318",
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(tel.conns); got != 2 {
+		t.Errorf("connections to two collectors = %d, want 2", got)
+	}
+	shutCtx, shutCancel = context.WithTimeout(context.Background(), time.Second)
+	tel.Shutdown(shutCtx) //nolint:errcheck
+	shutCancel()
+}
+
+func TestPerSignalEndpointDialing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:        "127.0.0.1:4317",
+		MetricsEndpoint: "127.0.0.1:4319",
+		Insecure:        true,
+		ServiceName:     "drone-runner-aws",
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}()
+
+	if got := len(tel.conns); got != 2 {
+		t.Fatalf("connections = %d, want separate log and metric conns", got)
+	}
+	targets := map[string]bool{}
+	for _, conn := range tel.conns {
+		targets[conn.Target()] = true
+	}
+	for _, want := range []string{"127.0.0.1:4317", "127.0.0.1:4319"} {
+		found := false
+		for target := range targets {
+			if strings.Contains(target, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no connection dialed %s, targets = %v", want, targets)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package telemetry
+
+import (
+	"context"
+)
+
+type metricRefKey struct{}
+
+// metricRef ties a log entry to a recorded metric observation.
+type metricRef struct {
+	name  string
+	value float64
+}
+
+// ContextWithMetricRef returns a context carrying a reference to a
+// recorded metric. When a log entry is emitted with this context, Fire
+// attaches the reference as metric.ref.name and metric.ref.value
+// attributes, tying the log line to the metric spike it describes.
+func ContextWithMetricRef(ctx context.Context, name string, value float64) context.Context {
+	return context.WithValue(ctx, metricRefKey{}, metricRef{name: name, value: value})
+}
+
+// metricRefFrom extracts a metric reference from ctx, if present.
+func metricRefFrom(ctx context.Context) (metricRef, bool) {
+	ref, ok := ctx.Value(metricRefKey{}).(metricRef)
+	return ref, ok
+}
+
+type exportMetadataKey struct{}
+
+// ContextWithExportMetadata returns a context carrying gRPC metadata
+// attached to the export RPC of any record emitted with it, for
+// multi-tenant collectors that route by a per-build header such as
+// X-Scope-OrgID. The metadata complements the static Headers, which
+// apply to every export.
+func ContextWithExportMetadata(ctx context.Context, md map[string]string) context.Context {
+	copied := make(map[string]string, len(md))
+	for k, v := range md {
+		copied[k] = v
+	}
+	return context.WithValue(ctx, exportMetadataKey{}, copied)
+}
+
+// exportMetadataFrom extracts per-request export metadata from ctx, if
+// present.
+func exportMetadataFrom(ctx context.Context) (map[string]string, bool) {
+	md, ok := ctx.Value(exportMetadataKey{}).(map[string]string)
+	return md, ok && len(md) > 0
+}
+
+type instanceIDKey struct{}
+
+// ContextWithInstanceID returns a context carrying the ID of the VM
+// instance a request is operating on. When a log entry is emitted with
+// this context, Fire attaches the ID as host.id and runner.instance_id
+// attributes, correlating the line with the specific VM. Unlike the
+// resource-level host attributes, which describe the runner itself,
+// the instance ID varies per request.
+func ContextWithInstanceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, instanceIDKey{}, id)
+}
+
+// instanceIDFrom extracts a VM instance ID from ctx, if present.
+func instanceIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(instanceIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+type attemptKey struct{}
+
+// ContextWithAttempt returns a context carrying the retry attempt
+// number of the operation a request is performing. When a log entry is
+// emitted with this context, Fire attaches the number as a
+// runner.attempt attribute, so logs from retried steps can be
+// correlated and told apart across attempts.
+func ContextWithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// attemptFrom extracts a retry attempt number from ctx, if present.
+func attemptFrom(ctx context.Context) (int, bool) {
+	attempt, ok := ctx.Value(attemptKey{}).(int)
+	return attempt, ok
+}
+
+type logPriorityKey struct{}
+
+// ContextWithLogPriority returns a context carrying a sampling priority
+// for log entries. The priority scales the configured sample ratio, so
+// values above 1 raise an entry's chance of being kept under sampling
+// pressure and values below 1 lower it. It has no effect when sampling
+// is disabled.
+func ContextWithLogPriority(ctx context.Context, priority float64) context.Context {
+	return context.WithValue(ctx, logPriorityKey{}, priority)
+}
+
+// logPriorityFrom extracts a sampling priority from ctx, if present.
+func logPriorityFrom(ctx context.Context) (float64, bool) {
+	priority, ok := ctx.Value(logPriorityKey{}).(float64)
+	return priority, ok
+}
@@ -0,0 +1,95 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// dumpLine is the on-disk form of one histogram data point.
+type dumpLine struct {
+	Time   time.Time `json:"time"`
+	Name   string    `json:"name"`
+	Count  uint64    `json:"count"`
+	Sum    float64   `json:"sum"`
+	Bounds []float64 `json:"bounds,omitempty"`
+	Counts []uint64  `json:"bucket_counts,omitempty"`
+}
+
+// dumpMetricExporter appends the histogram data points of every
+// collection to a local file as JSON lines, for offline analysis
+// alongside the OTLP export. It sits behind its own periodic reader,
+// so a slow disk never stalls the collector export.
+type dumpMetricExporter struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (e *dumpMetricExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (e *dumpMetricExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (e *dumpMetricExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	now := time.Now()
+	var lines []dumpLine
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					lines = append(lines, dumpLine{
+						Time:   now,
+						Name:   m.Name,
+						Count:  dp.Count,
+						Sum:    dp.Sum,
+						Bounds: dp.Bounds,
+						Counts: dp.BucketCounts,
+					})
+				}
+			case metricdata.Histogram[int64]:
+				for _, dp := range data.DataPoints {
+					lines = append(lines, dumpLine{
+						Time:   now,
+						Name:   m.Name,
+						Count:  dp.Count,
+						Sum:    float64(dp.Sum),
+						Bounds: dp.Bounds,
+						Counts: dp.BucketCounts,
+					})
+				}
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	f, err := os.OpenFile(e.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	enc := json.NewEncoder(f)
+	for _, line := range lines {
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *dumpMetricExporter) ForceFlush(context.Context) error { return nil }
+
+func (e *dumpMetricExporter) Shutdown(context.Context) error { return nil }
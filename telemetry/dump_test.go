@@ -0,0 +1,107 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetricsDumpFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "histograms.json")
+
+	dump := &dumpMetricExporter{path: path}
+	reader := sdkmetric.NewPeriodicReader(dump, sdkmetric.WithInterval(20*time.Millisecond))
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(ctx) //nolint:errcheck
+
+	hist, err := provider.Meter("test").Float64Histogram("runner.queue_wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.Record(ctx, 1.5)
+	hist.Record(ctx, 2.5)
+
+	// wait for at least two periodic collections to land in the file.
+	var lines []dumpLine
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lines = readDumpFile(t, path)
+		if len(lines) >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(lines) < 2 {
+		t.Fatalf("dump lines = %d, want at least 2 periodic snapshots", len(lines))
+	}
+	for _, line := range lines {
+		if line.Name != "runner.queue_wait" {
+			t.Errorf("dumped metric name = %q, want runner.queue_wait", line.Name)
+		}
+		if line.Count != 2 || line.Sum != 4 {
+			t.Errorf("dumped count/sum = %d/%v, want 2/4", line.Count, line.Sum)
+		}
+		if line.Time.IsZero() {
+			t.Error("dumped line has no timestamp")
+		}
+	}
+}
+
+func TestMetricsDumpSkipsNonHistograms(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "histograms.json")
+
+	dump := &dumpMetricExporter{path: path}
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(ctx) //nolint:errcheck
+
+	counter, err := provider.Meter("test").Int64Counter("runner.builds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 1)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	if err := dump.Export(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("dump file exists with only counter data, stat err = %v", err)
+	}
+}
+
+// readDumpFile decodes every line of the dump file; a missing file
+// reads as empty.
+func readDumpFile(t *testing.T, path string) []dumpLine {
+	t.Helper()
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	var lines []dumpLine
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var line dumpLine
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
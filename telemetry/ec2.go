@@ -0,0 +1,102 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// imdsBaseURL is the EC2 instance metadata service address. It is a
+// variable so tests can point the detector at a mock server.
+var imdsBaseURL = "http://169.254.169.254"
+
+// ec2Detector queries the EC2 instance metadata service (IMDS) for the
+// identity of the instance the runner itself is on. It speaks IMDSv2,
+// falling back to unauthenticated requests when the token endpoint is
+// unavailable, and honors the caller's context for the overall timeout.
+type ec2Detector struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewEC2Detector returns a resource detector reading the EC2 instance
+// metadata service. It is the detector Config.DetectEC2 enables; it is
+// exported so integrators can instead run it through
+// LazyResourceDetectors when startup must not wait on IMDS.
+func NewEC2Detector() resource.Detector {
+	return &ec2Detector{baseURL: imdsBaseURL, client: http.DefaultClient}
+}
+
+// Detect implements resource.Detector. It returns an error when IMDS is
+// unreachable, which callers treat as "not on EC2".
+func (d *ec2Detector) Detect(ctx context.Context) (*resource.Resource, error) {
+	token, _ := d.fetchToken(ctx)
+	fields := []struct {
+		path string
+		key  func(string) attribute.KeyValue
+	}{
+		{"instance-id", semconv.HostID},
+		{"instance-type", semconv.HostType},
+		{"placement/availability-zone", semconv.CloudAvailabilityZone},
+		{"placement/region", semconv.CloudRegion},
+		{"ami-id", semconv.HostImageID},
+	}
+	attrs := []attribute.KeyValue{semconv.CloudProviderAWS}
+	for _, field := range fields {
+		value, err := d.fetch(ctx, token, field.path)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, field.key(value))
+	}
+	return resource.NewSchemaless(attrs...), nil
+}
+
+// fetchToken requests an IMDSv2 session token. An error means the
+// detector falls back to unauthenticated IMDSv1 requests.
+func (d *ec2Detector) fetchToken(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, d.baseURL+"/latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("telemetry: IMDS token request returned %s", resp.Status)
+	}
+	token, err := io.ReadAll(resp.Body)
+	return string(token), err
+}
+
+// fetch reads one metadata field under /latest/meta-data.
+func (d *ec2Detector) fetch(ctx context.Context, token, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"/latest/meta-data/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("telemetry: query IMDS %s: %w", path, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("telemetry: IMDS %s returned %s", path, resp.Status)
+	}
+	value, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("telemetry: read IMDS %s: %w", path, err)
+	}
+	return string(value), nil
+}
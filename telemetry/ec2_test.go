@@ -0,0 +1,124 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// newMockIMDS serves the metadata fields the EC2 detector queries,
+// rejecting requests that skip the IMDSv2 token handshake.
+func newMockIMDS(t *testing.T) *httptest.Server {
+	t.Helper()
+	const token = "test-imds-token"
+	fields := map[string]string{
+		"instance-id":                 "i-0abc123def456",
+		"instance-type":               "m5.large",
+		"placement/availability-zone": "us-east-1a",
+		"placement/region":            "us-east-1",
+		"ami-id":                      "ami-0fedcba987",
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/latest/api/token" {
+			w.Write([]byte(token)) //nolint:errcheck
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		value, ok := fields[strings.TrimPrefix(r.URL.Path, "/latest/meta-data/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(value)) //nolint:errcheck
+	}))
+}
+
+func TestEC2Detector(t *testing.T) {
+	srv := newMockIMDS(t)
+	defer srv.Close()
+
+	detector := &ec2Detector{baseURL: srv.URL, client: srv.Client()}
+	res, err := detector.Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"cloud.provider":          "aws",
+		"cloud.region":            "us-east-1",
+		"cloud.availability_zone": "us-east-1a",
+		"host.id":                 "i-0abc123def456",
+		"host.type":               "m5.large",
+		"host.image.id":           "ami-0fedcba987",
+	}
+	for key, value := range want {
+		got, ok := res.Set().Value(attribute.Key(key))
+		if !ok || got.AsString() != value {
+			t.Errorf("%s = %v (present=%v), want %s", key, got.AsString(), ok, value)
+		}
+	}
+}
+
+func TestNewResourceDetectEC2(t *testing.T) {
+	srv := newMockIMDS(t)
+	defer srv.Close()
+	restore := imdsBaseURL
+	imdsBaseURL = srv.URL
+	defer func() { imdsBaseURL = restore }()
+
+	res, err := NewResource(context.Background(), Config{
+		ServiceName: "drone-runner-aws",
+		DetectEC2:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := res.Set().Value("cloud.region"); !ok || got.AsString() != "us-east-1" {
+		t.Errorf("cloud.region = %v (present=%v), want us-east-1", got.AsString(), ok)
+	}
+	if got, ok := res.Set().Value("cloud.provider"); !ok || got.AsString() != "aws" {
+		t.Errorf("cloud.provider = %v (present=%v), want aws", got.AsString(), ok)
+	}
+}
+
+func TestNewResourceDetectEC2Unreachable(t *testing.T) {
+	srv := newMockIMDS(t)
+	srv.Close()
+	restore := imdsBaseURL
+	imdsBaseURL = srv.URL
+	defer func() { imdsBaseURL = restore }()
+
+	hook := logrustest.NewGlobal()
+	defer hook.Reset()
+
+	res, err := NewResource(context.Background(), Config{
+		ServiceName: "drone-runner-aws",
+		DetectEC2:   true,
+	})
+	if err != nil {
+		t.Fatalf("NewResource() = %v with IMDS unreachable, want base resource", err)
+	}
+	if _, ok := res.Set().Value("cloud.provider"); ok {
+		t.Error("cloud attributes present despite IMDS being unreachable")
+	}
+	if _, ok := res.Set().Value("service.name"); !ok {
+		t.Error("base identity attributes missing")
+	}
+
+	warned := false
+	for _, entry := range hook.AllEntries() {
+		if strings.Contains(entry.Message, "EC2 metadata unavailable") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Error("no warning logged for unreachable IMDS")
+	}
+}
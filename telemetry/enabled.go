@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// watchEnabled polls the flag function and pauses or resumes both
+// export signals when it flips. The pipeline starts unpaused, so a flag
+// that is off at launch takes effect on the first evaluation. The
+// function and interval are passed in rather than read from t.config so
+// the goroutine never races a concurrent Reconfigure.
+func (t *Telemetry) watchEnabled(ctx context.Context, enabledFunc func() bool, interval time.Duration) {
+	defer t.wg.Done()
+	enabled := t.applyEnabledFlag(true, enabledFunc())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			enabled = t.applyEnabledFlag(enabled, enabledFunc())
+		}
+	}
+}
+
+// applyEnabledFlag transitions the pause state when the flag value
+// changed and returns the value now in force.
+func (t *Telemetry) applyEnabledFlag(previous, current bool) bool {
+	if current == previous {
+		return current
+	}
+	if current {
+		t.ResumeMetrics()
+		t.ResumeLogs()
+		logrus.Info("telemetry: export enabled by feature flag")
+	} else {
+		t.PauseMetrics()
+		t.PauseLogs()
+		logrus.Info("telemetry: export disabled by feature flag")
+	}
+	return current
+}
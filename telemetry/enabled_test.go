@@ -0,0 +1,59 @@
+package telemetry
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEnabledFuncTogglesExport(t *testing.T) {
+	var flag atomic.Bool
+	flag.Store(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tel := New(Config{
+		Endpoint:            "127.0.0.1:4317",
+		Insecure:            true,
+		ServiceName:         "drone-runner-aws",
+		EnabledFunc:         flag.Load,
+		EnabledPollInterval: 5 * time.Millisecond,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}()
+
+	paused := func() bool {
+		return tel.metricsPaused.Load() && tel.logsPaused.Load()
+	}
+	waitFor := func(want bool, what string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if paused() == want {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("poller never %s export", what)
+	}
+
+	// the flag is on, so export runs unpaused.
+	if paused() {
+		t.Fatal("export paused while the flag is on")
+	}
+
+	// flipping the flag off pauses both signals.
+	flag.Store(false)
+	waitFor(true, "paused")
+
+	// flipping it back on resumes them.
+	flag.Store(true)
+	waitFor(false, "resumed")
+}
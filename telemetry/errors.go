@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// ExportError annotates an export failure with the signal, endpoint and
+// attempt it came from, so the message reaching the global error
+// handler is actionable instead of an opaque SDK error. Attempt counts
+// consecutive failed exports and resets once one succeeds.
+type ExportError struct {
+	Signal   string
+	Endpoint string
+	Attempt  int
+	Err      error
+}
+
+func (e *ExportError) Error() string {
+	return fmt.Sprintf("telemetry: %s export to %s failed (attempt %d): %v",
+		e.Signal, e.Endpoint, e.Attempt, e.Err)
+}
+
+func (e *ExportError) Unwrap() error {
+	return e.Err
+}
+
+// annotatedLogExporter wraps log export failures in ExportError.
+type annotatedLogExporter struct {
+	sdklog.Exporter
+	endpoint string
+	attempts atomic.Int64
+}
+
+func (e *annotatedLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	if err := e.Exporter.Export(ctx, records); err != nil {
+		return &ExportError{Signal: "logs", Endpoint: e.endpoint, Attempt: int(e.attempts.Add(1)), Err: err}
+	}
+	e.attempts.Store(0)
+	return nil
+}
+
+// annotatedMetricExporter wraps metric export failures in ExportError.
+type annotatedMetricExporter struct {
+	sdkmetric.Exporter
+	endpoint string
+	attempts atomic.Int64
+}
+
+func (e *annotatedMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if err := e.Exporter.Export(ctx, rm); err != nil {
+		return &ExportError{Signal: "metrics", Endpoint: e.endpoint, Attempt: int(e.attempts.Add(1)), Err: err}
+	}
+	e.attempts.Store(0)
+	return nil
+}
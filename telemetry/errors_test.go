@@ -0,0 +1,78 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// flakyLogExporter fails until its remaining failure budget is spent.
+type flakyLogExporter struct {
+	failures int
+}
+
+func (e *flakyLogExporter) Export(context.Context, []sdklog.Record) error {
+	if e.failures > 0 {
+		e.failures--
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func (e *flakyLogExporter) Shutdown(context.Context) error   { return nil }
+func (e *flakyLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestExportErrorAnnotation(t *testing.T) {
+	ctx := context.Background()
+	exporter := &annotatedLogExporter{
+		Exporter: &flakyLogExporter{failures: 2},
+		endpoint: "otel.example.com:4317",
+	}
+
+	err := exporter.Export(ctx, nil)
+	var exportErr *ExportError
+	if !errors.As(err, &exportErr) {
+		t.Fatalf("Export() = %v, want *ExportError", err)
+	}
+	if exportErr.Signal != "logs" {
+		t.Errorf("Signal = %q, want %q", exportErr.Signal, "logs")
+	}
+	if exportErr.Endpoint != "otel.example.com:4317" {
+		t.Errorf("Endpoint = %q, want %q", exportErr.Endpoint, "otel.example.com:4317")
+	}
+	if exportErr.Attempt != 1 {
+		t.Errorf("Attempt = %d, want 1", exportErr.Attempt)
+	}
+	for _, want := range []string{"logs", "otel.example.com:4317", "attempt 1", "unavailable"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Error() = %q, missing %q", err.Error(), want)
+		}
+	}
+
+	// consecutive failures advance the attempt counter.
+	err = exporter.Export(ctx, nil)
+	if !errors.As(err, &exportErr) || exportErr.Attempt != 2 {
+		t.Errorf("second failure = %v, want attempt 2", err)
+	}
+
+	// a success resets it.
+	if err := exporter.Export(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	exporter.Exporter = &flakyLogExporter{failures: 1}
+	err = exporter.Export(ctx, nil)
+	if !errors.As(err, &exportErr) || exportErr.Attempt != 1 {
+		t.Errorf("failure after success = %v, want attempt 1", err)
+	}
+}
+
+func TestExportErrorUnwrap(t *testing.T) {
+	inner := errors.New("deadline exceeded")
+	err := error(&ExportError{Signal: "metrics", Endpoint: "127.0.0.1:4317", Attempt: 3, Err: inner})
+	if !errors.Is(err, inner) {
+		t.Error("errors.Is() does not reach the wrapped error")
+	}
+}
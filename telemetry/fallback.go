@@ -0,0 +1,74 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// fallbackMaxSize is the size at which the fallback file is rotated.
+const fallbackMaxSize = 10 << 20 // 10 MiB
+
+// fallbackLogExporter spills log records to a local file while the
+// collector is unreachable, so nothing is lost during an extended
+// outage and the file can be replayed later. The primary exporter is
+// skipped entirely while the connection sits in TransientFailure.
+type fallbackLogExporter struct {
+	sdklog.Exporter
+	conn *grpc.ClientConn
+	path string
+	mu   sync.Mutex
+}
+
+func (e *fallbackLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	if e.conn == nil || e.conn.GetState() != connectivity.TransientFailure {
+		if err := e.Exporter.Export(ctx, records); err == nil {
+			return nil
+		}
+	}
+	return e.spill(records)
+}
+
+// spill appends the records to the fallback file as JSON lines,
+// rotating the file once it exceeds fallbackMaxSize.
+func (e *fallbackLogExporter) spill(records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if info, err := os.Stat(e.path); err == nil && info.Size() > fallbackMaxSize {
+		if err := os.Rename(e.path, e.path+".1"); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(e.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	enc := json.NewEncoder(f)
+	for i := range records {
+		attrs := map[string]interface{}{}
+		records[i].WalkAttributes(func(kv attribute.KeyValue) bool {
+			attrs[string(kv.Key)] = kv.Value.AsInterface()
+			return true
+		})
+		line := map[string]interface{}{
+			"time":     records[i].Timestamp(),
+			"severity": records[i].SeverityText(),
+			"body":     records[i].Body().Emit(),
+		}
+		if len(attrs) > 0 {
+			line["attributes"] = attrs
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
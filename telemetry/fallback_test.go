@@ -0,0 +1,54 @@
+package telemetry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/log/logtest"
+)
+
+func TestFallbackLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fallback.jsonl")
+	primary := &failingLogExporter{}
+	exporter := &fallbackLogExporter{Exporter: primary, path: path}
+
+	record := logtest.RecordFactory{
+		SeverityText: "error",
+		Body:         attribute.StringValue("collector unreachable"),
+		Attributes:   []attribute.KeyValue{attribute.String("pool", "ubuntu")},
+	}.NewRecord()
+
+	if err := exporter.Export(context.Background(), []sdklog.Record{record}); err != nil {
+		t.Fatal(err)
+	}
+	if primary.calls != 1 {
+		t.Errorf("primary export attempts = %d, want 1", primary.calls)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"collector unreachable", `"severity":"error"`, `"pool":"ubuntu"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("fallback file missing %q in %q", want, data)
+		}
+	}
+}
+
+func TestFallbackNotUsedWhenPrimaryHealthy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fallback.jsonl")
+	exporter := &fallbackLogExporter{Exporter: &countingLogExporter{}, path: path}
+
+	if err := exporter.Export(context.Background(), []sdklog.Record{{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("fallback file written while the primary exporter is healthy")
+	}
+}
@@ -0,0 +1,181 @@
+package telemetry
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// headerSource supplies the per-RPC metadata attached to every export,
+// so headers can be swapped without dropping the connection.
+type headerSource struct {
+	mu      sync.RWMutex
+	headers map[string]string
+}
+
+func (s *headerSource) set(headers map[string]string) {
+	s.mu.Lock()
+	s.headers = headers
+	s.mu.Unlock()
+}
+
+func (s *headerSource) get() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.headers
+}
+
+// unaryInterceptor injects the current headers as outgoing metadata on
+// every export RPC.
+func (s *headerSource) unaryInterceptor(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	for k, v := range s.get() {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// perRPCMetadata injects metadata carried on the emit context into the
+// export RPC, complementing the static header source.
+type perRPCMetadata struct{}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (perRPCMetadata) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	md, _ := exportMetadataFrom(ctx)
+	return md, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials.
+// The metadata is routing information, not a credential, so it may
+// travel over insecure connections too.
+func (perRPCMetadata) RequireTransportSecurity() bool { return false }
+
+// loadHeaders returns the effective export headers: the static Headers
+// from the configuration overlaid with the contents of HeadersFile,
+// when one is configured.
+func (t *Telemetry) loadHeaders() (map[string]string, error) {
+	headers := map[string]string{}
+	for k, v := range t.config.Headers {
+		headers[k] = v
+	}
+	if t.config.HeadersFile == "" {
+		return headers, nil
+	}
+	data, err := os.ReadFile(t.config.HeadersFile)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range parseHeaders(data) {
+		headers[k] = v
+	}
+	return headers, nil
+}
+
+// parseHeaders parses KEY=VALUE lines. Blank lines and lines starting
+// with # are skipped.
+func parseHeaders(data []byte) map[string]string {
+	headers := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// maskHeaders renders header keys with masked values, for log output
+// that must not leak credentials.
+func maskHeaders(headers map[string]string) string {
+	if len(headers) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k+"=***")
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// headersEqual reports whether two header sets carry the same values.
+func headersEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshHeaders re-reads the headers file and rebuilds the exporters
+// when the parsed values differ from those currently in use. It reports
+// whether a reconfiguration happened, so an unchanged file never causes
+// a reconnect.
+func (t *Telemetry) refreshHeaders(ctx context.Context) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.started {
+		return false, nil
+	}
+	headers, err := t.loadHeaders()
+	if err != nil {
+		return false, err
+	}
+	if headersEqual(headers, t.headers) {
+		return false, nil
+	}
+	if err := t.buildProviders(ctx, headers); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateHeaders atomically replaces the headers attached to every
+// export request. Unlike a headers-file refresh it swaps the header
+// source used by the RPC interceptor in place, without rebuilding the
+// exporters or dropping the collector connection.
+func (t *Telemetry) UpdateHeaders(headers map[string]string) {
+	copied := make(map[string]string, len(headers))
+	for k, v := range headers {
+		copied[k] = v
+	}
+	t.mu.Lock()
+	t.headers = copied
+	t.mu.Unlock()
+	t.headerSource.set(copied)
+}
+
+// watchHeaders periodically refreshes the headers file until the
+// context is cancelled. The interval is passed in rather than read from
+// t.config so the goroutine never races a concurrent Reconfigure.
+func (t *Telemetry) watchHeaders(ctx context.Context, interval time.Duration) {
+	defer t.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := t.refreshHeaders(ctx); err != nil {
+				otel.Handle(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package telemetry
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestParseHeaders(t *testing.T) {
+	data := []byte("# collector auth\nAuthorization=Bearer token\n\nX-Tenant = acme\nmalformed\n")
+	want := map[string]string{
+		"Authorization": "Bearer token",
+		"X-Tenant":      "acme",
+	}
+	if got := parseHeaders(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseHeaders() = %v, want %v", got, want)
+	}
+}
+
+func TestRefreshHeaders(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "headers")
+	if err := os.WriteFile(file, []byte("Authorization=Bearer one\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+		HeadersFile: file,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer tel.Shutdown(ctx) //nolint:errcheck
+
+	before := tel.loggerProvider
+
+	// an unchanged file must not trigger a reconfiguration.
+	changed, err := tel.refreshHeaders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("refreshHeaders() reconfigured on unchanged file")
+	}
+	if tel.loggerProvider != before {
+		t.Error("providers replaced on unchanged file")
+	}
+
+	// a changed file must rebuild the exporters.
+	if err := os.WriteFile(file, []byte("Authorization=Bearer two\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = tel.refreshHeaders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("refreshHeaders() did not reconfigure on changed file")
+	}
+	if tel.loggerProvider == before {
+		t.Error("providers not replaced on changed file")
+	}
+	if got := tel.headers["Authorization"]; got != "Bearer two" {
+		t.Errorf("effective Authorization header = %q, want %q", got, "Bearer two")
+	}
+}
+
+// captureLogsServer records the metadata of incoming log exports.
+type captureLogsServer struct {
+	collogspb.UnimplementedLogsServiceServer
+	md chan metadata.MD
+}
+
+func (s *captureLogsServer) Export(ctx context.Context, _ *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		select {
+		case s.md <- md:
+		default:
+		}
+	}
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func TestPerRequestExportMetadata(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	captured := make(chan metadata.MD, 4)
+	srv := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(srv, &captureLogsServer{md: captured})
+	go srv.Serve(lis) //nolint:errcheck
+	defer srv.Stop()
+
+	tel := New(Config{
+		Endpoint:    lis.Addr().String(),
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+	})
+	if err := tel.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		tel.Shutdown(ctx) //nolint:errcheck
+	}()
+
+	ctx := ContextWithExportMetadata(context.Background(), map[string]string{"x-scope-orgid": "acct-1"})
+	entry := &logrus.Entry{Time: time.Now(), Level: logrus.InfoLevel, Message: "tenant export", Context: ctx, Data: logrus.Fields{}}
+	if err := tel.hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case md := <-captured:
+		if got := md.Get("x-scope-orgid"); len(got) != 1 || got[0] != "acct-1" {
+			t.Errorf("x-scope-orgid = %v, want [acct-1]", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no export received")
+	}
+
+	// entries without export metadata carry none.
+	entry = &logrus.Entry{Time: time.Now(), Level: logrus.InfoLevel, Message: "plain export", Data: logrus.Fields{}}
+	if err := tel.hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case md := <-captured:
+		if got := md.Get("x-scope-orgid"); len(got) != 0 {
+			t.Errorf("x-scope-orgid on plain export = %v, want none", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no export received")
+	}
+}
+
+func TestUpdateHeaders(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	captured := make(chan metadata.MD, 4)
+	srv := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(srv, &captureLogsServer{md: captured})
+	go srv.Serve(lis) //nolint:errcheck
+	defer srv.Stop()
+
+	tel := New(Config{
+		Endpoint:    lis.Addr().String(),
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+		Headers:     map[string]string{"x-token": "one"},
+	})
+	if err := tel.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		tel.Shutdown(ctx) //nolint:errcheck
+	}()
+
+	emit := func() {
+		entry := &logrus.Entry{
+			Time:    time.Now(),
+			Level:   logrus.InfoLevel,
+			Message: "rotation check",
+			Data:    logrus.Fields{},
+		}
+		if err := tel.hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive := func() metadata.MD {
+		select {
+		case md := <-captured:
+			return md
+		case <-time.After(5 * time.Second):
+			t.Fatal("no export received")
+			return nil
+		}
+	}
+
+	emit()
+	if got := receive().Get("x-token"); len(got) != 1 || got[0] != "one" {
+		t.Errorf("x-token = %v, want [one]", got)
+	}
+
+	conn := tel.conns[0]
+	tel.UpdateHeaders(map[string]string{"x-token": "two"})
+	if tel.conns[0] != conn {
+		t.Error("UpdateHeaders rebuilt the collector connection")
+	}
+
+	emit()
+	if got := receive().Get("x-token"); len(got) != 1 || got[0] != "two" {
+		t.Errorf("x-token after update = %v, want [two]", got)
+	}
+}
@@ -0,0 +1,564 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exportedMarker is the field set on entries after the hook ships
+// them, so setups that also log through another sink can dedup.
+const exportedMarker = "_otel_exported"
+
+// Exported reports whether the entry was already shipped by the hook.
+// Downstream sinks can consult it to avoid double-shipping.
+func Exported(entry *logrus.Entry) bool {
+	v, ok := entry.Data[exportedMarker].(bool)
+	return ok && v
+}
+
+// redactedValue replaces the value of entry fields matching a
+// configured redaction key.
+const redactedValue = "***REDACTED***"
+
+// Hook is a logrus hook that converts each entry into an OpenTelemetry
+// log record and emits it through the telemetry pipeline.
+type Hook struct {
+	telemetry        *Telemetry
+	redactKeys       atomic.Value // []string, lowercased
+	contextAllowlist atomic.Value // map[string]struct{}
+	contextDenylist  atomic.Value // map[string]struct{}
+	minLevel         *logrus.Level
+}
+
+// SetRedactKeys configures field names whose values are replaced with
+// a redaction marker before the record is built, so credentials logged
+// into entry fields never reach the collector. Matching is
+// case-insensitive and by substring, so "secret" also covers keys like
+// aws_secret_key. It may be called while the hook is live; nil clears
+// the list.
+func (h *Hook) SetRedactKeys(keys []string) {
+	lowered := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lowered = append(lowered, strings.ToLower(key))
+	}
+	h.redactKeys.Store(lowered)
+}
+
+// SetContextAllowlist restricts which keys from the context map (see
+// UpdateContext) are attached to records. A non-empty list keeps only
+// the named keys; nil or empty keeps every key. It may be called while
+// the hook is live.
+func (h *Hook) SetContextAllowlist(keys []string) {
+	h.contextAllowlist.Store(keySet(keys))
+}
+
+// SetContextDenylist strips the named keys from the context map before
+// attaching it to records, for high-cardinality fields that must not
+// reach the backend. It applies after any allowlist. It may be called
+// while the hook is live; nil clears the list.
+func (h *Hook) SetContextDenylist(keys []string) {
+	h.contextDenylist.Store(keySet(keys))
+}
+
+// keySet converts a key list to its set form.
+func keySet(keys []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		set[key] = struct{}{}
+	}
+	return set
+}
+
+// filterContextAttributes applies the configured allowlist and denylist
+// to the context attributes.
+func (h *Hook) filterContextAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	allow, _ := h.contextAllowlist.Load().(map[string]struct{})
+	deny, _ := h.contextDenylist.Load().(map[string]struct{})
+	if len(allow) == 0 && len(deny) == 0 {
+		return attrs
+	}
+	kept := attrs[:0:0]
+	for _, kv := range attrs {
+		if len(allow) > 0 {
+			if _, ok := allow[string(kv.Key)]; !ok {
+				continue
+			}
+		}
+		if _, ok := deny[string(kv.Key)]; ok {
+			continue
+		}
+		kept = append(kept, kv)
+	}
+	return kept
+}
+
+// redactAttributes replaces the value of any attribute whose key
+// matches a configured redaction key.
+func (h *Hook) redactAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	keys, _ := h.redactKeys.Load().([]string)
+	if len(keys) == 0 {
+		return attrs
+	}
+	for i, kv := range attrs {
+		lowered := strings.ToLower(string(kv.Key))
+		for _, key := range keys {
+			if strings.Contains(lowered, key) {
+				attrs[i] = attribute.String(string(kv.Key), redactedValue)
+				break
+			}
+		}
+	}
+	return attrs
+}
+
+// Levels implements logrus.Hook. With Config.LogLevel set, only levels
+// at or above the threshold are returned, so logrus never hands the
+// hook entries below it; unset keeps every level.
+func (h *Hook) Levels() []logrus.Level {
+	if h.minLevel == nil {
+		return logrus.AllLevels
+	}
+	return logrus.AllLevels[:*h.minLevel+1]
+}
+
+// installedHook wraps the telemetry hook behind a kill switch, since
+// logrus has no way to remove a hook once added. A stale registration
+// is switched off instead.
+type installedHook struct {
+	hook     *Hook
+	disabled atomic.Bool
+}
+
+// Levels implements logrus.Hook.
+func (h *installedHook) Levels() []logrus.Level {
+	return h.hook.Levels()
+}
+
+// Fire implements logrus.Hook.
+func (h *installedHook) Fire(entry *logrus.Entry) error {
+	if h.disabled.Load() {
+		return nil
+	}
+	return h.hook.Fire(entry)
+}
+
+// Fire implements logrus.Hook. Entries are dropped while the pipeline
+// is stopped. A panic while converting or emitting a record is
+// recovered and counted rather than taking down the caller that merely
+// logged something.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	defer func() {
+		if r := recover(); r != nil {
+			h.telemetry.recordHookPanic(r)
+		}
+	}()
+	logger := h.telemetry.emitLogger()
+	if logger == nil {
+		logger = h.telemetry.initLazy()
+	}
+	if logger == nil {
+		return nil
+	}
+	if h.telemetry.logsPaused.Load() {
+		return nil
+	}
+
+	// while the collector connection recovers from an outage, shed
+	// everything below error level to reduce load on it.
+	if h.telemetry.degraded.Load() && entry.Level > logrus.ErrorLevel {
+		return nil
+	}
+
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// error-and-above entries always count towards the error-rate
+	// metric, even when sampling drops the record itself.
+	if entry.Level <= logrus.ErrorLevel {
+		if counter := h.telemetry.errorCounter(); counter != nil {
+			counter.Add(ctx, 1, metric.WithAttributes(attribute.String("level", entry.Level.String())))
+		}
+	}
+	if !h.sampled(ctx) {
+		return nil
+	}
+	if h.telemetry.spanForLevel(entry.Level) &&
+		!trace.SpanContextFromContext(ctx).IsValid() {
+		var span trace.Span
+		ctx, span = otel.Tracer(instrumentationName).Start(ctx, "log."+entry.Level.String())
+		span.SetStatus(otelcodes.Error, entry.Message)
+		defer span.End()
+	}
+
+	var record log.Record
+	record.SetTimestamp(entry.Time)
+	body := bodyValue(h.telemetry.bodyFormat(), entry)
+	truncated := false
+	if max := h.telemetry.maxBodyLength(); max > 0 && len(body) > max {
+		body = truncateBody(body, max)
+		truncated = true
+	}
+	record.SetBody(attribute.StringValue(body))
+	sev := severity(entry.Level)
+	if h.telemetry.elevateLogsWithError() && sev < log.SeverityWarn {
+		if _, ok := entry.Data[logrus.ErrorKey]; ok {
+			sev = log.SeverityWarn
+		}
+	}
+	record.SetSeverity(sev)
+	record.SetSeverityText(entry.Level.String())
+	merged := h.buildAttributes(ctx, entry, truncated)
+	record.AddAttributes(merged...)
+	if attrsHist, bodyHist := h.telemetry.ingestHistograms(); attrsHist != nil {
+		attrsHist.Record(ctx, int64(len(merged)))
+		bodyHist.Record(ctx, int64(len(body)))
+	}
+	emitCtx := ctx
+	if !h.telemetry.inheritDeadline() {
+		// detach from the request's deadline so the export is not
+		// canceled when the request that logged it completes.
+		emitCtx = context.WithoutCancel(ctx)
+	}
+	release, ok := h.telemetry.acquireEmitSlot(emitCtx)
+	if !ok {
+		// saturated: shedding the record here keeps the caller from
+		// blocking behind a slow exporter.
+		h.telemetry.recordsDropped.Add(1)
+		return nil
+	}
+	defer release()
+	logger.Emit(emitCtx, record)
+	entry.Data[exportedMarker] = true
+	return nil
+}
+
+// buildAttributes assembles the full attribute set of a record —
+// entry fields, context attributes, and the configured hook-derived
+// extras — with the duplicate strategy and redaction applied.
+func (h *Hook) buildAttributes(ctx context.Context, entry *logrus.Entry, truncated bool) []attribute.KeyValue {
+	jsonComplex := h.telemetry.jsonEncodeComplexFields()
+	mapHTTP := h.telemetry.mapHTTPSemconv()
+	var entryAttrs, hookAttrs []attribute.KeyValue
+	for k, v := range entry.Data {
+		if k == exportedMarker {
+			continue
+		}
+		if mapHTTP {
+			if kv, ok := httpSemconvAttribute(k, v); ok {
+				entryAttrs = append(entryAttrs, kv)
+				continue
+			}
+		}
+		// durations are emitted numerically, as backends prefer
+		// milliseconds over strings like "1.5s".
+		if d, ok := v.(time.Duration); ok {
+			entryAttrs = append(entryAttrs,
+				attribute.Int64(k, d.Milliseconds()),
+				attribute.String(k+".unit", "ms"),
+			)
+			continue
+		}
+		entryAttrs = append(entryAttrs, fieldAttribute(jsonComplex, k, v))
+		if err, ok := v.(error); ok && k == logrus.ErrorKey {
+			hookAttrs = append(hookAttrs, errorAttributes(err)...)
+		}
+	}
+
+	if truncated {
+		hookAttrs = append(hookAttrs, attribute.Bool("body_truncated", true))
+	}
+	hookAttrs = append(hookAttrs, h.filterContextAttributes(h.telemetry.contextAttributes())...)
+	if h.telemetry.emitHostShortname() && h.telemetry.hostname != "" {
+		hookAttrs = append(hookAttrs, attribute.String("host.shortname", h.telemetry.hostname))
+	}
+	if h.telemetry.emitActiveJobs() {
+		hookAttrs = append(hookAttrs, attribute.Int64("runner.active_jobs", h.telemetry.activeJobs.Load()))
+	}
+	if h.telemetry.emitMessageBytes() {
+		hookAttrs = append(hookAttrs, attribute.Int("log.message_bytes", len(entry.Message)))
+	}
+	if h.telemetry.emitTraceFlags() {
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			hookAttrs = append(hookAttrs,
+				attribute.String("trace.flags", sc.TraceFlags().String()),
+				attribute.Bool("trace.sampled", sc.IsSampled()),
+			)
+		}
+	}
+	if id, ok := instanceIDFrom(ctx); ok {
+		hookAttrs = append(hookAttrs,
+			attribute.String("host.id", id),
+			attribute.String("runner.instance_id", id),
+		)
+	}
+	if attempt, ok := attemptFrom(ctx); ok {
+		hookAttrs = append(hookAttrs, attribute.Int("runner.attempt", attempt))
+	}
+	if ref, ok := metricRefFrom(ctx); ok {
+		hookAttrs = append(hookAttrs,
+			attribute.String("metric.ref.name", ref.name),
+			attribute.Float64("metric.ref.value", ref.value),
+		)
+	}
+	return h.redactAttributes(mergeAttributes(h.telemetry.duplicateStrategy(), entryAttrs, hookAttrs))
+}
+
+// DescribeLogAttributes returns the attributes Fire would attach to
+// the given entry — context filtering, redaction, semconv mapping, and
+// typing included — without emitting anything, so operators can
+// preview attribute shaping before shipping records. It works whether
+// or not the pipeline is started.
+func (h *Hook) DescribeLogAttributes(entry *logrus.Entry) []attribute.KeyValue {
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	truncated := false
+	if max := h.telemetry.maxBodyLength(); max > 0 && len(bodyValue(h.telemetry.bodyFormat(), entry)) > max {
+		truncated = true
+	}
+	return h.buildAttributes(ctx, entry, truncated)
+}
+
+// httpSemconvAttribute maps a well-known HTTP entry field to its OTEL
+// semantic-convention equivalent. The latency_ms value converts to the
+// duration attribute's unit, seconds. The second return is false for
+// fields that are not HTTP-shaped, or whose value has an unexpected
+// type; those keep their original key.
+func httpSemconvAttribute(k string, v interface{}) (attribute.KeyValue, bool) {
+	switch k {
+	case "method":
+		if s, ok := v.(string); ok {
+			return attribute.String("http.request.method", s), true
+		}
+	case "path":
+		if s, ok := v.(string); ok {
+			return attribute.String("url.path", s), true
+		}
+	case "status":
+		switch val := v.(type) {
+		case int:
+			return attribute.Int("http.response.status_code", val), true
+		case int64:
+			return attribute.Int64("http.response.status_code", val), true
+		case float64:
+			return attribute.Int("http.response.status_code", int(val)), true
+		}
+	case "latency_ms":
+		switch val := v.(type) {
+		case int:
+			return attribute.Float64("http.server.request.duration", float64(val)/1000), true
+		case int64:
+			return attribute.Float64("http.server.request.duration", float64(val)/1000), true
+		case float64:
+			return attribute.Float64("http.server.request.duration", val/1000), true
+		}
+	}
+	return attribute.KeyValue{}, false
+}
+
+// fieldAttribute converts an entry field into an attribute, keeping
+// the native type for the common scalar kinds so integers, floats, and
+// booleans survive to the backend instead of arriving as strings.
+// Everything else renders through fieldValue. Context attributes stay
+// string-typed; see contextAttributes.
+func fieldAttribute(jsonComplex bool, k string, v interface{}) attribute.KeyValue {
+	switch val := v.(type) {
+	case string:
+		return attribute.String(k, val)
+	case []byte:
+		return attribute.String(k, string(val))
+	case bool:
+		return attribute.Bool(k, val)
+	case int:
+		return attribute.Int64(k, int64(val))
+	case int64:
+		return attribute.Int64(k, val)
+	case float64:
+		return attribute.Float64(k, val)
+	default:
+		return attribute.String(k, fieldValue(jsonComplex, v))
+	}
+}
+
+// fieldValue renders an entry field as an attribute value. With JSON
+// encoding enabled, maps and slices marshal to compact JSON; anything
+// else, and values that fail to marshal, use %v formatting.
+func fieldValue(jsonComplex bool, v interface{}) string {
+	if jsonComplex {
+		switch reflect.ValueOf(v).Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array:
+			if data, err := json.Marshal(v); err == nil {
+				return string(data)
+			}
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// mergeAttributes combines entry and hook-derived attributes according
+// to the duplicate strategy. Unknown strategies behave as
+// DuplicateKeepBoth.
+func mergeAttributes(strategy string, entryAttrs, hookAttrs []attribute.KeyValue) []attribute.KeyValue {
+	switch strategy {
+	case DuplicateEntryWins:
+		return append(entryAttrs, withoutKeys(hookAttrs, entryAttrs)...)
+	case DuplicateContextWins:
+		return append(withoutKeys(entryAttrs, hookAttrs), hookAttrs...)
+	default:
+		return append(entryAttrs, hookAttrs...)
+	}
+}
+
+// withoutKeys returns the attributes from attrs whose key does not
+// appear in winners.
+func withoutKeys(attrs, winners []attribute.KeyValue) []attribute.KeyValue {
+	keys := make(map[attribute.Key]struct{}, len(winners))
+	for _, kv := range winners {
+		keys[kv.Key] = struct{}{}
+	}
+	kept := attrs[:0:0]
+	for _, kv := range attrs {
+		if _, ok := keys[kv.Key]; !ok {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
+
+// errorAttributes derives structured attributes from known error
+// wrappers, so backends can filter on status codes instead of message
+// text.
+func errorAttributes(err error) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if s, ok := status.FromError(err); ok && s.Code() != codes.OK {
+		attrs = append(attrs, attribute.String("rpc.grpc.status_code", s.Code().String()))
+	}
+	var coder interface{ Code() string }
+	if errors.As(err, &coder) {
+		attrs = append(attrs, attribute.String("error.code", coder.Code()))
+	}
+	attrs = append(attrs, attribute.String("exception.message", err.Error()))
+	if causes := errorCauses(err); len(causes) > 0 {
+		attrs = append(attrs, attribute.StringSlice("exception.causes", causes))
+	}
+	return attrs
+}
+
+// errorCauses returns the message of each layer below err, outermost
+// first, following the %w unwrap chain. A plain unwrapped error has no
+// causes.
+func errorCauses(err error) []string {
+	var causes []string
+	for err = errors.Unwrap(err); err != nil; err = errors.Unwrap(err) {
+		causes = append(causes, err.Error())
+	}
+	return causes
+}
+
+// sampled applies the configured sample ratio, scaled by any priority
+// carried on the context, and reports whether the entry is kept.
+func (h *Hook) sampled(ctx context.Context) bool {
+	ratio := h.telemetry.sampleRatio()
+	if ratio <= 0 {
+		return true
+	}
+	if priority, ok := logPriorityFrom(ctx); ok {
+		ratio *= priority
+	}
+	if ratio >= 1 {
+		return true
+	}
+	return rand.Float64() < ratio //nolint:gosec
+}
+
+// truncateBody caps the body at max bytes without splitting a
+// multi-byte rune. The body ships in a proto3 string field, and the
+// OTLP exporter rejects invalid UTF-8, so a byte-offset cut through a
+// rune would fail the whole export instead of shortening the message.
+// The caller guarantees len(body) > max.
+func truncateBody(body string, max int) string {
+	cut := max
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return body[:cut]
+}
+
+// bodyValue renders the record body for the given format. Unknown
+// formats fall back to the plain message.
+func bodyValue(format string, entry *logrus.Entry) string {
+	switch format {
+	case LogBodyMessageWithFields:
+		if len(entry.Data) == 0 {
+			return entry.Message
+		}
+		keys := make([]string, 0, len(entry.Data))
+		for k := range entry.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString(entry.Message)
+		for _, k := range keys {
+			fmt.Fprintf(&b, " %s=%v", k, entry.Data[k])
+		}
+		return b.String()
+	case LogBodyJSON:
+		m := make(map[string]interface{}, len(entry.Data)+1)
+		m["message"] = entry.Message
+		for k, v := range entry.Data {
+			m[k] = fmt.Sprint(v)
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			return entry.Message
+		}
+		return string(data)
+	default:
+		return entry.Message
+	}
+}
+
+// severity maps a logrus level to the equivalent OpenTelemetry log
+// severity.
+func severity(level logrus.Level) log.Severity {
+	switch level {
+	case logrus.TraceLevel:
+		return log.SeverityTrace
+	case logrus.DebugLevel:
+		return log.SeverityDebug
+	case logrus.InfoLevel:
+		return log.SeverityInfo
+	case logrus.WarnLevel:
+		return log.SeverityWarn
+	case logrus.ErrorLevel:
+		return log.SeverityError
+	case logrus.FatalLevel:
+		return log.SeverityFatal
+	case logrus.PanicLevel:
+		return log.SeverityFatal4
+	default:
+		return log.SeverityUndefined
+	}
+}
@@ -0,0 +1,1294 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/embedded"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordingLogger captures emitted records and their contexts for
+// assertions.
+type recordingLogger struct {
+	embedded.Logger
+	records []log.Record
+	ctxs    []context.Context
+}
+
+func (l *recordingLogger) Emit(ctx context.Context, r log.Record) {
+	l.records = append(l.records, r)
+	l.ctxs = append(l.ctxs, ctx)
+}
+
+func (l *recordingLogger) Enabled(context.Context, log.EnabledParameters) bool {
+	return true
+}
+
+// newTestHook returns a hook wired to a recording logger instead of a
+// live pipeline.
+func newTestHook(config Config) (*Hook, *recordingLogger) {
+	tel := New(config)
+	rec := &recordingLogger{}
+	tel.logger = rec
+	return tel.hook, rec
+}
+
+// recordAttributes flattens a record's attributes for assertions.
+func recordAttributes(r log.Record) map[string]interface{} {
+	attrs := map[string]interface{}{}
+	r.WalkAttributes(func(kv attribute.KeyValue) bool {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+		return true
+	})
+	return attrs
+}
+
+func TestExportedMarker(t *testing.T) {
+	hook, rec := newTestHook(Config{})
+
+	entry := &logrus.Entry{Message: "vm ready", Data: logrus.Fields{"pool": "ubuntu"}}
+	if Exported(entry) {
+		t.Error("Exported() = true before Fire")
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if !Exported(entry) {
+		t.Error("Exported() = false after Fire")
+	}
+
+	// the marker must not leak into the exported attributes, even when
+	// an already-marked entry is fired again.
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range rec.records {
+		if _, ok := recordAttributes(record)[exportedMarker]; ok {
+			t.Error("exported marker leaked into record attributes")
+		}
+	}
+}
+
+func TestMetricRefAttributes(t *testing.T) {
+	hook, rec := newTestHook(Config{})
+
+	ctx := ContextWithMetricRef(context.Background(), "runner.queue.wait", 42.5)
+	entry := &logrus.Entry{Message: "queue spike", Context: ctx, Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.records) != 1 {
+		t.Fatalf("emitted records = %d, want 1", len(rec.records))
+	}
+	attrs := recordAttributes(rec.records[0])
+	if attrs["metric.ref.name"] != "runner.queue.wait" {
+		t.Errorf("metric.ref.name = %v, want %q", attrs["metric.ref.name"], "runner.queue.wait")
+	}
+	if attrs["metric.ref.value"] != 42.5 {
+		t.Errorf("metric.ref.value = %v, want 42.5", attrs["metric.ref.value"])
+	}
+
+	// without a metric ref on the context the attributes are absent.
+	rec.records = nil
+	if err := hook.Fire(&logrus.Entry{Message: "plain", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	attrs = recordAttributes(rec.records[0])
+	if _, ok := attrs["metric.ref.name"]; ok {
+		t.Error("metric.ref.name present without a metric ref on the context")
+	}
+}
+
+func TestActiveJobsAttribute(t *testing.T) {
+	tel := New(Config{EmitActiveJobs: true})
+	rec := &recordingLogger{}
+	tel.logger = rec
+
+	tel.SetActiveJobs(3)
+	if err := tel.hook.Fire(&logrus.Entry{Message: "job started", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if attrs["runner.active_jobs"] != int64(3) {
+		t.Errorf("runner.active_jobs = %v, want 3", attrs["runner.active_jobs"])
+	}
+
+	// the attribute tracks the current count.
+	rec.records = nil
+	tel.SetActiveJobs(1)
+	if err := tel.hook.Fire(&logrus.Entry{Message: "job done", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	attrs = recordAttributes(rec.records[0])
+	if attrs["runner.active_jobs"] != int64(1) {
+		t.Errorf("runner.active_jobs = %v, want 1", attrs["runner.active_jobs"])
+	}
+
+	// without the toggle the attribute is absent.
+	hook, rec := newTestHook(Config{})
+	if err := hook.Fire(&logrus.Entry{Message: "plain", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recordAttributes(rec.records[0])["runner.active_jobs"]; ok {
+		t.Error("runner.active_jobs present with EmitActiveJobs disabled")
+	}
+}
+
+func TestHostShortnameAttribute(t *testing.T) {
+	hook, rec := newTestHook(Config{EmitHostShortname: true})
+	if err := hook.Fire(&logrus.Entry{Message: "short host", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	want := hostShortname()
+	if want == "" {
+		t.Skip("hostname not determinable on this machine")
+	}
+	if attrs["host.shortname"] != want {
+		t.Errorf("host.shortname = %v, want %q", attrs["host.shortname"], want)
+	}
+	if strings.Contains(want, ".") {
+		t.Errorf("short hostname %q still carries a domain", want)
+	}
+
+	// without the toggle the attribute is absent.
+	hook, rec = newTestHook(Config{})
+	if err := hook.Fire(&logrus.Entry{Message: "plain", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recordAttributes(rec.records[0])["host.shortname"]; ok {
+		t.Error("host.shortname present with EmitHostShortname disabled")
+	}
+}
+
+func TestDurationAttributes(t *testing.T) {
+	hook, rec := newTestHook(Config{})
+
+	entry := &logrus.Entry{
+		Message: "vm booted",
+		Data:    logrus.Fields{"boot_time": 1500 * time.Millisecond},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if attrs["boot_time"] != int64(1500) {
+		t.Errorf("boot_time = %v (%T), want 1500", attrs["boot_time"], attrs["boot_time"])
+	}
+	if attrs["boot_time.unit"] != "ms" {
+		t.Errorf("boot_time.unit = %v, want ms", attrs["boot_time.unit"])
+	}
+}
+
+func TestEmitInheritsDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ctx = ContextWithInstanceID(ctx, "i-0123456789")
+	time.Sleep(20 * time.Millisecond)
+
+	// by default the emit inherits the expired request deadline.
+	hook, rec := newTestHook(Config{})
+	entry := &logrus.Entry{Message: "late", Context: ctx, Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if rec.ctxs[0].Err() == nil {
+		t.Error("emit context not canceled with an expired entry deadline")
+	}
+
+	// when detached, the emit survives the deadline but keeps the
+	// context's values.
+	hook, rec = newTestHook(Config{EmitInheritsDeadline: boolPtr(false)})
+	entry = &logrus.Entry{Message: "late", Context: ctx, Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if rec.ctxs[0].Err() != nil {
+		t.Error("emit context canceled despite detaching from the deadline")
+	}
+	if _, ok := instanceIDFrom(rec.ctxs[0]); !ok {
+		t.Error("context values lost by detaching from the deadline")
+	}
+}
+
+func TestDuplicateAttributeStrategy(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  string
+		wantCount int
+		wantValue interface{}
+	}{
+		{
+			name:      "keep both",
+			strategy:  DuplicateKeepBoth,
+			wantCount: 2,
+		},
+		{
+			name:      "entry wins",
+			strategy:  DuplicateEntryWins,
+			wantCount: 1,
+			wantValue: "from-entry",
+		},
+		{
+			name:      "context wins",
+			strategy:  DuplicateContextWins,
+			wantCount: 1,
+			wantValue: "i-0123456789",
+		},
+		{
+			name:      "default keeps both",
+			strategy:  "",
+			wantCount: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, rec := newTestHook(Config{DuplicateAttributeStrategy: tt.strategy})
+
+			// the entry field collides with the hook-derived host.id.
+			ctx := ContextWithInstanceID(context.Background(), "i-0123456789")
+			entry := &logrus.Entry{
+				Message: "collision",
+				Context: ctx,
+				Data:    logrus.Fields{"host.id": "from-entry"},
+			}
+			if err := hook.Fire(entry); err != nil {
+				t.Fatal(err)
+			}
+
+			count := 0
+			var value interface{}
+			rec.records[0].WalkAttributes(func(kv attribute.KeyValue) bool {
+				if kv.Key == "host.id" {
+					count++
+					value = kv.Value.AsInterface()
+				}
+				return true
+			})
+			if count != tt.wantCount {
+				t.Errorf("host.id occurrences = %d, want %d", count, tt.wantCount)
+			}
+			if tt.wantValue != nil && value != tt.wantValue {
+				t.Errorf("host.id = %v, want %v", value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestDegradedModeLevelFilter(t *testing.T) {
+	hook, rec := newTestHook(Config{})
+	hook.telemetry.degraded.Store(true)
+
+	fire := func(level logrus.Level) {
+		t.Helper()
+		entry := &logrus.Entry{Message: "degraded", Level: level, Data: logrus.Fields{}}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// while degraded only error-and-above records pass.
+	fire(logrus.InfoLevel)
+	fire(logrus.WarnLevel)
+	if len(rec.records) != 0 {
+		t.Errorf("emitted %d sub-error records while degraded, want 0", len(rec.records))
+	}
+	fire(logrus.ErrorLevel)
+	fire(logrus.FatalLevel)
+	if len(rec.records) != 2 {
+		t.Errorf("emitted %d error-and-above records while degraded, want 2", len(rec.records))
+	}
+
+	// recovery restores the configured behavior.
+	hook.telemetry.degraded.Store(false)
+	fire(logrus.InfoLevel)
+	if len(rec.records) != 3 {
+		t.Errorf("emitted %d records after recovery, want 3", len(rec.records))
+	}
+}
+
+func TestInstanceIDAttributes(t *testing.T) {
+	hook, rec := newTestHook(Config{})
+
+	ctx := ContextWithInstanceID(context.Background(), "i-0123456789")
+	entry := &logrus.Entry{Message: "vm provisioned", Context: ctx, Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if attrs["runner.instance_id"] != "i-0123456789" {
+		t.Errorf("runner.instance_id = %v, want i-0123456789", attrs["runner.instance_id"])
+	}
+	if attrs["host.id"] != "i-0123456789" {
+		t.Errorf("host.id = %v, want i-0123456789", attrs["host.id"])
+	}
+
+	// without an instance ID on the context the attributes are absent.
+	rec.records = nil
+	if err := hook.Fire(&logrus.Entry{Message: "plain", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recordAttributes(rec.records[0])["runner.instance_id"]; ok {
+		t.Error("runner.instance_id present without an instance ID on the context")
+	}
+}
+
+// codedError carries a machine-readable code alongside the message.
+type codedError struct{ code string }
+
+func (e *codedError) Error() string { return "coded failure" }
+func (e *codedError) Code() string  { return e.code }
+
+func TestErrorAttributes(t *testing.T) {
+	hook, rec := newTestHook(Config{})
+
+	// a gRPC status error surfaces its status code.
+	entry := &logrus.Entry{
+		Message: "provision failed",
+		Data:    logrus.Fields{logrus.ErrorKey: status.Error(codes.Unavailable, "no capacity")},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if attrs["rpc.grpc.status_code"] != "Unavailable" {
+		t.Errorf("rpc.grpc.status_code = %v, want Unavailable", attrs["rpc.grpc.status_code"])
+	}
+
+	// an error exposing Code() string surfaces error.code.
+	rec.records = nil
+	entry = &logrus.Entry{
+		Message: "driver error",
+		Data:    logrus.Fields{logrus.ErrorKey: &codedError{code: "THROTTLED"}},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs = recordAttributes(rec.records[0])
+	if attrs["error.code"] != "THROTTLED" {
+		t.Errorf("error.code = %v, want THROTTLED", attrs["error.code"])
+	}
+}
+
+func TestExceptionCauseChain(t *testing.T) {
+	hook, rec := newTestHook(Config{})
+
+	root := errors.New("connection refused")
+	mid := fmt.Errorf("dial collector: %w", root)
+	top := fmt.Errorf("provision vm: %w", mid)
+	entry := &logrus.Entry{
+		Message: "provision failed",
+		Data:    logrus.Fields{logrus.ErrorKey: top},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if attrs["exception.message"] != "provision vm: dial collector: connection refused" {
+		t.Errorf("exception.message = %v, want the full chain", attrs["exception.message"])
+	}
+	causes, ok := attrs["exception.causes"].([]string)
+	if !ok {
+		t.Fatalf("exception.causes = %T, want []string", attrs["exception.causes"])
+	}
+	want := []string{"dial collector: connection refused", "connection refused"}
+	if len(causes) != len(want) {
+		t.Fatalf("exception.causes = %v, want %v", causes, want)
+	}
+	for i := range want {
+		if causes[i] != want[i] {
+			t.Errorf("exception.causes[%d] = %q, want %q", i, causes[i], want[i])
+		}
+	}
+
+	// an unwrapped error reports its message with no causes.
+	rec.records = nil
+	entry = &logrus.Entry{
+		Message: "plain failure",
+		Data:    logrus.Fields{logrus.ErrorKey: errors.New("boom")},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs = recordAttributes(rec.records[0])
+	if attrs["exception.message"] != "boom" {
+		t.Errorf("exception.message = %v, want boom", attrs["exception.message"])
+	}
+	if _, ok := attrs["exception.causes"]; ok {
+		t.Error("exception.causes present for an unwrapped error")
+	}
+}
+
+func TestSpanForErrorLogs(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prev)
+
+	hook, rec := newTestHook(Config{SpanForErrorLogs: true})
+
+	// an error entry without an active span gets a short-lived span,
+	// and the record is emitted within it.
+	entry := &logrus.Entry{Message: "provision failed", Level: logrus.ErrorLevel, Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("recorded spans = %d, want 1", len(spans))
+	}
+	if got := spans[0].Name(); got != "log.error" {
+		t.Errorf("span name = %q, want %q", got, "log.error")
+	}
+	sc := trace.SpanContextFromContext(rec.ctxs[0])
+	if !sc.IsValid() {
+		t.Fatal("record emitted without a span context")
+	}
+	if sc.SpanID() != spans[0].SpanContext().SpanID() {
+		t.Error("record span ID does not match the created span")
+	}
+
+	// an info entry is left alone.
+	if err := hook.Fire(&logrus.Entry{Message: "vm ready", Level: logrus.InfoLevel, Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Ended()) != 1 {
+		t.Error("span created for a non-error entry")
+	}
+
+	// an entry that already carries a span keeps it.
+	ctx, span := provider.Tracer("test").Start(context.Background(), "job")
+	entry = &logrus.Entry{Message: "job failed", Level: logrus.ErrorLevel, Context: ctx, Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+	if got := trace.SpanContextFromContext(rec.ctxs[2]).SpanID(); got != span.SpanContext().SpanID() {
+		t.Error("existing span replaced for an error entry")
+	}
+}
+
+func TestLogPrioritySampling(t *testing.T) {
+	const entries = 500
+	hook, rec := newTestHook(Config{LogSampleRatio: 0.5})
+
+	fire := func(ctx context.Context) int {
+		t.Helper()
+		rec.records = nil
+		for i := 0; i < entries; i++ {
+			entry := &logrus.Entry{Message: "sampled", Context: ctx, Data: logrus.Fields{}}
+			if err := hook.Fire(entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return len(rec.records)
+	}
+
+	// a high priority lifts the effective ratio to 1, keeping all.
+	if kept := fire(ContextWithLogPriority(context.Background(), 10)); kept != entries {
+		t.Errorf("high priority kept %d of %d entries, want all", kept, entries)
+	}
+	// a zero priority drops everything.
+	if kept := fire(ContextWithLogPriority(context.Background(), 0)); kept != 0 {
+		t.Errorf("zero priority kept %d entries, want 0", kept)
+	}
+	// without a priority the configured ratio applies.
+	kept := fire(context.Background())
+	if kept == 0 || kept == entries {
+		t.Errorf("default priority kept %d of %d entries, want a sampled subset", kept, entries)
+	}
+}
+
+func TestBodyValue(t *testing.T) {
+	entry := &logrus.Entry{
+		Message: "vm ready",
+		Data: logrus.Fields{
+			"pool": "ubuntu",
+			"id":   42,
+		},
+	}
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{
+			name:   "default message",
+			format: "",
+			want:   "vm ready",
+		},
+		{
+			name:   "message only",
+			format: LogBodyMessage,
+			want:   "vm ready",
+		},
+		{
+			name:   "message with fields",
+			format: LogBodyMessageWithFields,
+			want:   "vm ready id=42 pool=ubuntu",
+		},
+		{
+			name:   "json",
+			format: LogBodyJSON,
+			want:   `{"id":"42","message":"vm ready","pool":"ubuntu"}`,
+		},
+		{
+			name:   "unknown falls back to message",
+			format: "yaml",
+			want:   "vm ready",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bodyValue(tt.format, entry); got != tt.want {
+				t.Errorf("bodyValue(%q) = %q, want %q", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxBodyLength(t *testing.T) {
+	hook, rec := newTestHook(Config{MaxBodyLength: 16})
+
+	long := strings.Repeat("goroutine stack ", 64)
+	entry := &logrus.Entry{Message: long, Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.records) != 1 {
+		t.Fatalf("records = %d, want 1", len(rec.records))
+	}
+	if got := rec.records[0].Body().AsString(); got != long[:16] {
+		t.Errorf("body = %q, want first 16 bytes of the message", got)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if got, ok := attrs["body_truncated"].(bool); !ok || !got {
+		t.Errorf("body_truncated = %v, want true", attrs["body_truncated"])
+	}
+
+	// a multi-byte rune straddling the limit is dropped whole, so the
+	// truncated body stays valid UTF-8 and survives the OTLP marshal.
+	// "é" occupies bytes 15 and 16; a byte-offset cut at 16 would split
+	// it.
+	entry = &logrus.Entry{Message: "provisioning vmé done", Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	got := rec.records[1].Body().AsString()
+	if !utf8.ValidString(got) {
+		t.Errorf("body %q is not valid UTF-8", got)
+	}
+	if want := "provisioning vm"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+
+	// short messages are passed through unmarked.
+	entry = &logrus.Entry{Message: "vm ready", Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.records[2].Body().AsString(); got != "vm ready" {
+		t.Errorf("body = %q, want %q", got, "vm ready")
+	}
+	if _, ok := recordAttributes(rec.records[2])["body_truncated"]; ok {
+		t.Error("body_truncated set on a short message")
+	}
+}
+
+func TestElevateLogsWithError(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		entry  *logrus.Entry
+		want   log.Severity
+	}{
+		{
+			name:   "info with error elevated",
+			config: Config{ElevateLogsWithError: true},
+			entry: &logrus.Entry{Level: logrus.InfoLevel, Message: "provision failed softly",
+				Data: logrus.Fields{logrus.ErrorKey: errors.New("boom")}},
+			want: log.SeverityWarn,
+		},
+		{
+			name:   "info without error untouched",
+			config: Config{ElevateLogsWithError: true},
+			entry:  &logrus.Entry{Level: logrus.InfoLevel, Message: "vm ready", Data: logrus.Fields{}},
+			want:   log.SeverityInfo,
+		},
+		{
+			name:   "error level keeps its own severity",
+			config: Config{ElevateLogsWithError: true},
+			entry: &logrus.Entry{Level: logrus.ErrorLevel, Message: "provision failed",
+				Data: logrus.Fields{logrus.ErrorKey: errors.New("boom")}},
+			want: log.SeverityError,
+		},
+		{
+			name:   "disabled keeps the level's severity",
+			config: Config{},
+			entry: &logrus.Entry{Level: logrus.InfoLevel, Message: "provision failed softly",
+				Data: logrus.Fields{logrus.ErrorKey: errors.New("boom")}},
+			want: log.SeverityInfo,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, rec := newTestHook(tt.config)
+			if err := hook.Fire(tt.entry); err != nil {
+				t.Fatal(err)
+			}
+			if got := rec.records[0].Severity(); got != tt.want {
+				t.Errorf("severity = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONEncodeComplexFields(t *testing.T) {
+	fields := logrus.Fields{
+		"ports": []int{8080, 9090},
+		"tags":  map[string]string{"pool": "ubuntu", "zone": "us-east-1a"},
+		"count": 7,
+	}
+
+	// enabled: maps and slices marshal to compact JSON.
+	hook, rec := newTestHook(Config{JSONEncodeComplexFields: true})
+	entry := &logrus.Entry{Message: "provisioned", Data: fields}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if got := attrs["ports"]; got != "[8080,9090]" {
+		t.Errorf("ports = %v, want JSON array", got)
+	}
+	if got := attrs["tags"]; got != `{"pool":"ubuntu","zone":"us-east-1a"}` {
+		t.Errorf("tags = %v, want JSON object", got)
+	}
+	// scalars keep their native type either way.
+	if got := attrs["count"]; got != int64(7) {
+		t.Errorf("count = %v (%T), want 7", got, got)
+	}
+
+	// disabled: everything keeps %v formatting.
+	hook, rec = newTestHook(Config{})
+	entry = &logrus.Entry{Message: "provisioned", Data: fields}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs = recordAttributes(rec.records[0])
+	if got := attrs["ports"]; got != "[8080 9090]" {
+		t.Errorf("ports = %v, want %%v formatting", got)
+	}
+
+	// unmarshalable values fall back to %v.
+	if got := fieldValue(true, []interface{}{func() {}}); got == "" {
+		t.Error("fieldValue() empty for unmarshalable value")
+	}
+}
+
+func TestSpanLevels(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prev)
+
+	hook, _ := newTestHook(Config{
+		SpanForErrorLogs: true,
+		SpanLevels:       []string{"warning", "fatal"},
+	})
+
+	fire := func(level logrus.Level) {
+		t.Helper()
+		entry := &logrus.Entry{Message: "level check", Level: level, Data: logrus.Fields{}}
+		if err := hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only the configured levels create spans; the error-and-above
+	// default no longer applies.
+	fire(logrus.WarnLevel)
+	fire(logrus.ErrorLevel)
+	fire(logrus.InfoLevel)
+	fire(logrus.FatalLevel)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded spans = %d, want 2", len(spans))
+	}
+	if got := spans[0].Name(); got != "log.warning" {
+		t.Errorf("first span = %q, want %q", got, "log.warning")
+	}
+	if got := spans[1].Name(); got != "log.fatal" {
+		t.Errorf("second span = %q, want %q", got, "log.fatal")
+	}
+
+	// without SpanForErrorLogs the level list alone creates nothing.
+	hook, _ = newTestHook(Config{SpanLevels: []string{"warning"}})
+	fire(logrus.WarnLevel)
+	if len(recorder.Ended()) != 2 {
+		t.Error("span created with SpanForErrorLogs disabled")
+	}
+}
+
+// panickyLogger panics on every emit, standing in for a logger whose
+// exporter was torn down underneath it.
+type panickyLogger struct {
+	embedded.Logger
+}
+
+func (panickyLogger) Emit(context.Context, log.Record) {
+	panic("emit on torn-down exporter")
+}
+
+func (panickyLogger) Enabled(context.Context, log.EnabledParameters) bool {
+	return true
+}
+
+func TestHookPanicRecovery(t *testing.T) {
+	ctx := context.Background()
+	tel := New(Config{})
+	tel.logger = panickyLogger{}
+
+	reader := sdkmetric.NewManualReader()
+	tel.meterProvider = sdkmetric.NewMeterProvider(tel.meterProviderOptions(reader)...)
+	defer tel.meterProvider.Shutdown(ctx) //nolint:errcheck
+	if err := tel.registerPanicCounter(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a panicking emit must not propagate to the logging caller.
+	for i := 0; i < 3; i++ {
+		entry := &logrus.Entry{Message: "panic check", Level: logrus.InfoLevel, Data: logrus.Fields{}}
+		if err := tel.hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "otel_log_hook_panics_total" {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("unexpected data type %T", m.Data)
+			}
+			for _, dp := range sum.DataPoints {
+				total += dp.Value
+			}
+		}
+	}
+	if total != 3 {
+		t.Errorf("otel_log_hook_panics_total = %d, want 3", total)
+	}
+}
+
+func TestInstallHookTwice(t *testing.T) {
+	tel := New(Config{})
+	rec := &recordingLogger{}
+	tel.logger = rec
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	// a reload re-running setup installs the hook a second time.
+	tel.InstallHook(logger)
+	tel.InstallHook(logger)
+
+	logger.Info("reload check")
+	if got := len(rec.records); got != 1 {
+		t.Errorf("exported records = %d, want 1 despite two installs", got)
+	}
+
+	// logrus still carries both registrations; only one is live.
+	if got := len(logger.Hooks[logrus.InfoLevel]); got != 2 {
+		t.Errorf("registered hooks = %d, want 2", got)
+	}
+}
+
+func TestEmitTraceFlags(t *testing.T) {
+	hook, rec := newTestHook(Config{EmitTraceFlags: true})
+
+	spanCtx := func(flags trace.TraceFlags) context.Context {
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{0x01},
+			SpanID:     trace.SpanID{0x02},
+			TraceFlags: flags,
+		})
+		return trace.ContextWithSpanContext(context.Background(), sc)
+	}
+
+	// a sampled span context reports its flags and the sampled bit.
+	entry := &logrus.Entry{
+		Message: "sampled",
+		Context: spanCtx(trace.FlagsSampled),
+		Data:    logrus.Fields{},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if attrs["trace.flags"] != "01" {
+		t.Errorf("trace.flags = %v, want 01", attrs["trace.flags"])
+	}
+	if attrs["trace.sampled"] != true {
+		t.Errorf("trace.sampled = %v, want true", attrs["trace.sampled"])
+	}
+
+	// an unsampled one reports flags 00.
+	rec.records = nil
+	entry = &logrus.Entry{
+		Message: "unsampled",
+		Context: spanCtx(0),
+		Data:    logrus.Fields{},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs = recordAttributes(rec.records[0])
+	if attrs["trace.flags"] != "00" {
+		t.Errorf("trace.flags = %v, want 00", attrs["trace.flags"])
+	}
+	if attrs["trace.sampled"] != false {
+		t.Errorf("trace.sampled = %v, want false", attrs["trace.sampled"])
+	}
+
+	// without a span context the attributes are absent.
+	rec.records = nil
+	if err := hook.Fire(&logrus.Entry{Message: "no span", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recordAttributes(rec.records[0])["trace.flags"]; ok {
+		t.Error("trace.flags present without a span context")
+	}
+
+	// and without the option span contexts add nothing.
+	hook, rec = newTestHook(Config{})
+	entry = &logrus.Entry{
+		Message: "disabled",
+		Context: spanCtx(trace.FlagsSampled),
+		Data:    logrus.Fields{},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recordAttributes(rec.records[0])["trace.flags"]; ok {
+		t.Error("trace.flags present with EmitTraceFlags disabled")
+	}
+}
+
+func TestFieldAttributeTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{name: "string", value: "ubuntu", want: "ubuntu"},
+		{name: "bytes", value: []byte("raw"), want: "raw"},
+		{name: "bool", value: true, want: true},
+		{name: "int", value: 42, want: int64(42)},
+		{name: "int64", value: int64(-7), want: int64(-7)},
+		{name: "float64", value: 1.5, want: 1.5},
+		{name: "error falls back to its message", value: errors.New("boom"), want: "boom"},
+		{name: "struct falls back to %v", value: struct{ N int }{N: 3}, want: "{3}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, rec := newTestHook(Config{})
+			entry := &logrus.Entry{Message: "typed", Data: logrus.Fields{"field": tt.value}}
+			if err := hook.Fire(entry); err != nil {
+				t.Fatal(err)
+			}
+			got := recordAttributes(rec.records[0])["field"]
+			if got != tt.want {
+				t.Errorf("field = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmitMessageBytes(t *testing.T) {
+	hook, rec := newTestHook(Config{EmitMessageBytes: true})
+
+	// a multi-byte message reports its byte length, not rune count.
+	msg := "provision café"
+	entry := &logrus.Entry{Message: msg, Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if got := attrs["log.message_bytes"]; got != int64(len(msg)) {
+		t.Errorf("log.message_bytes = %v, want %d", got, len(msg))
+	}
+
+	// the attribute is absent when the flag is off.
+	hook, rec = newTestHook(Config{})
+	if err := hook.Fire(&logrus.Entry{Message: msg, Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recordAttributes(rec.records[0])["log.message_bytes"]; ok {
+		t.Error("log.message_bytes present without EmitMessageBytes")
+	}
+}
+
+func TestMapHTTPSemconv(t *testing.T) {
+	hook, rec := newTestHook(Config{MapHTTPSemconv: true})
+
+	entry := &logrus.Entry{
+		Message: "request complete",
+		Data: logrus.Fields{
+			"method":     "GET",
+			"status":     200,
+			"path":       "/api/v1/pools",
+			"latency_ms": 250,
+			"pool":       "ubuntu",
+		},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if attrs["http.request.method"] != "GET" {
+		t.Errorf("http.request.method = %v, want GET", attrs["http.request.method"])
+	}
+	if attrs["http.response.status_code"] != int64(200) {
+		t.Errorf("http.response.status_code = %v, want 200", attrs["http.response.status_code"])
+	}
+	if attrs["url.path"] != "/api/v1/pools" {
+		t.Errorf("url.path = %v, want /api/v1/pools", attrs["url.path"])
+	}
+	if attrs["http.server.request.duration"] != 0.25 {
+		t.Errorf("http.server.request.duration = %v, want 0.25", attrs["http.server.request.duration"])
+	}
+	// mapped fields lose their original keys; others are untouched.
+	for _, key := range []string{"method", "status", "path", "latency_ms"} {
+		if _, ok := attrs[key]; ok {
+			t.Errorf("original key %q still present after mapping", key)
+		}
+	}
+	if attrs["pool"] != "ubuntu" {
+		t.Errorf("pool = %v, want ubuntu", attrs["pool"])
+	}
+
+	// without the flag the fields keep their original keys.
+	hook, rec = newTestHook(Config{})
+	if err := hook.Fire(&logrus.Entry{
+		Message: "request complete",
+		Data:    logrus.Fields{"method": "GET"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	attrs = recordAttributes(rec.records[0])
+	if attrs["method"] != "GET" {
+		t.Errorf("method = %v, want GET without MapHTTPSemconv", attrs["method"])
+	}
+	if _, ok := attrs["http.request.method"]; ok {
+		t.Error("http.request.method present without MapHTTPSemconv")
+	}
+}
+
+// blockingLogger parks every Emit until released, simulating a slow
+// synchronous exporter.
+type blockingLogger struct {
+	embedded.Logger
+	entered chan struct{}
+	release chan struct{}
+	emits   atomic.Int64
+}
+
+func (l *blockingLogger) Emit(ctx context.Context, r log.Record) {
+	l.emits.Add(1)
+	l.entered <- struct{}{}
+	<-l.release
+}
+
+func (l *blockingLogger) Enabled(context.Context, log.EnabledParameters) bool {
+	return true
+}
+
+func TestMaxConcurrentEmits(t *testing.T) {
+	tel := New(Config{MaxConcurrentEmits: 1})
+	blocker := &blockingLogger{entered: make(chan struct{}, 4), release: make(chan struct{})}
+	tel.logger = blocker
+	tel.emitSlots = make(chan struct{}, 1)
+
+	fire := func() error {
+		return tel.hook.Fire(&logrus.Entry{Message: "load", Data: logrus.Fields{}})
+	}
+
+	// the first Fire occupies the only slot inside the blocked emit.
+	firstDone := make(chan error, 1)
+	go func() { firstDone <- fire() }()
+	<-blocker.entered
+
+	// a second Fire finds the semaphore saturated and drops.
+	if err := fire(); err != nil {
+		t.Fatal(err)
+	}
+	if got := tel.recordsDropped.Load(); got != 1 {
+		t.Errorf("recordsDropped = %d, want 1", got)
+	}
+	if got := blocker.emits.Load(); got != 1 {
+		t.Errorf("concurrent emits = %d, want 1", got)
+	}
+
+	// releasing the exporter frees the slot for the next Fire.
+	close(blocker.release)
+	if err := <-firstDone; err != nil {
+		t.Fatal(err)
+	}
+	if err := fire(); err != nil {
+		t.Fatal(err)
+	}
+	<-blocker.entered
+	if got := blocker.emits.Load(); got != 2 {
+		t.Errorf("emits after release = %d, want 2", got)
+	}
+}
+
+func TestEmitAcquireTimeout(t *testing.T) {
+	tel := New(Config{MaxConcurrentEmits: 1, EmitAcquireTimeout: 20 * time.Millisecond})
+	blocker := &blockingLogger{entered: make(chan struct{}, 4), release: make(chan struct{})}
+	tel.logger = blocker
+	tel.emitSlots = make(chan struct{}, 1)
+
+	go tel.hook.Fire(&logrus.Entry{Message: "load", Data: logrus.Fields{}}) //nolint:errcheck
+	<-blocker.entered
+
+	// the saturated Fire waits for the timeout, then drops.
+	start := time.Now()
+	if err := tel.hook.Fire(&logrus.Entry{Message: "load", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("saturated Fire returned after %v, want at least the 20ms timeout", waited)
+	}
+	if got := tel.recordsDropped.Load(); got != 1 {
+		t.Errorf("recordsDropped = %d, want 1", got)
+	}
+	close(blocker.release)
+}
+
+func TestAttemptAttribute(t *testing.T) {
+	hook, rec := newTestHook(Config{})
+
+	ctx := ContextWithAttempt(context.Background(), 3)
+	entry := &logrus.Entry{Message: "step retried", Context: ctx, Data: logrus.Fields{}}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if attrs["runner.attempt"] != int64(3) {
+		t.Errorf("runner.attempt = %v (%T), want 3", attrs["runner.attempt"], attrs["runner.attempt"])
+	}
+
+	// without an attempt on the context the attribute is absent.
+	rec.records = nil
+	if err := hook.Fire(&logrus.Entry{Message: "first try", Data: logrus.Fields{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recordAttributes(rec.records[0])["runner.attempt"]; ok {
+		t.Error("runner.attempt present without an attempt on the context")
+	}
+}
+
+func TestRedactKeys(t *testing.T) {
+	hook, rec := newTestHook(Config{})
+	hook.SetRedactKeys([]string{"password", "secret", "TOKEN"})
+	hook.telemetry.UpdateContext(map[string]string{"api_token": "ctx-credential"})
+
+	entry := &logrus.Entry{
+		Message: "provisioning",
+		Data: logrus.Fields{
+			"password":       "hunter2",
+			"aws_secret_key": "AKIA-example",
+			"pool":           "ubuntu",
+		},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	attrs := recordAttributes(rec.records[0])
+	if attrs["password"] != redactedValue {
+		t.Errorf("password = %v, want redacted", attrs["password"])
+	}
+	// substring match: the configured "secret" covers aws_secret_key.
+	if attrs["aws_secret_key"] != redactedValue {
+		t.Errorf("aws_secret_key = %v, want redacted", attrs["aws_secret_key"])
+	}
+	// case-insensitive match against the context-provided key.
+	if attrs["api_token"] != redactedValue {
+		t.Errorf("api_token = %v, want redacted", attrs["api_token"])
+	}
+	if attrs["pool"] != "ubuntu" {
+		t.Errorf("pool = %v, want to pass through unchanged", attrs["pool"])
+	}
+
+	// clearing the list stops redaction.
+	hook.SetRedactKeys(nil)
+	rec.records = nil
+	if err := hook.Fire(&logrus.Entry{Message: "again", Data: logrus.Fields{"password": "hunter2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := recordAttributes(rec.records[0])["password"]; got != "hunter2" {
+		t.Errorf("password after clearing = %v, want hunter2", got)
+	}
+}
+
+func TestLogLevelThreshold(t *testing.T) {
+	// unset keeps the logrus default of every level.
+	tel := New(Config{})
+	if got := len(tel.Hook().Levels()); got != len(logrus.AllLevels) {
+		t.Errorf("default Levels() length = %d, want all %d", got, len(logrus.AllLevels))
+	}
+
+	tel = New(Config{LogLevel: "warning"})
+	levels := tel.Hook().Levels()
+	want := map[logrus.Level]bool{logrus.PanicLevel: true, logrus.FatalLevel: true, logrus.ErrorLevel: true, logrus.WarnLevel: true}
+	if len(levels) != len(want) {
+		t.Fatalf("Levels() = %v, want warn and above", levels)
+	}
+	for _, level := range levels {
+		if !want[level] {
+			t.Errorf("Levels() includes %v below the warn threshold", level)
+		}
+	}
+
+	if err := (Config{Endpoint: "127.0.0.1:4317", LogLevel: "verbose"}).Validate(); err == nil {
+		t.Error("Validate() = nil for an unknown LogLevel")
+	}
+	if err := (Config{Endpoint: "127.0.0.1:4317", LogLevel: "info"}).Validate(); err != nil {
+		t.Errorf("Validate() = %v for a valid LogLevel", err)
+	}
+}
+
+func TestContextAllowlistDenylist(t *testing.T) {
+	hook, rec := newTestHook(Config{})
+	hook.telemetry.UpdateContext(map[string]string{
+		"account":  "acme",
+		"region":   "us-east-1",
+		"build_id": "12345",
+	})
+
+	fire := func() map[string]interface{} {
+		t.Helper()
+		rec.records = nil
+		if err := hook.Fire(&logrus.Entry{Message: "filter check", Data: logrus.Fields{}}); err != nil {
+			t.Fatal(err)
+		}
+		return recordAttributes(rec.records[0])
+	}
+
+	// the allowlist keeps only the named context keys.
+	hook.SetContextAllowlist([]string{"account", "region"})
+	attrs := fire()
+	if attrs["account"] != "acme" || attrs["region"] != "us-east-1" {
+		t.Errorf("allowlisted keys missing, attrs = %v", attrs)
+	}
+	if _, ok := attrs["build_id"]; ok {
+		t.Error("build_id attached despite not being allowlisted")
+	}
+
+	// the denylist strips keys, applying after the allowlist.
+	hook.SetContextDenylist([]string{"region"})
+	attrs = fire()
+	if attrs["account"] != "acme" {
+		t.Errorf("account = %v, want acme", attrs["account"])
+	}
+	if _, ok := attrs["region"]; ok {
+		t.Error("region attached despite being denylisted")
+	}
+
+	// clearing both restores every context key, including later updates.
+	hook.SetContextAllowlist(nil)
+	hook.SetContextDenylist(nil)
+	hook.telemetry.UpdateContext(map[string]string{"pool": "ubuntu"})
+	attrs = fire()
+	for _, key := range []string{"account", "region", "build_id", "pool"} {
+		if _, ok := attrs[key]; !ok {
+			t.Errorf("%s missing after clearing the filters, attrs = %v", key, attrs)
+		}
+	}
+}
+
+func TestDescribeLogAttributes(t *testing.T) {
+	configs := map[string]Config{
+		"default": {},
+		"shaping": {
+			MapHTTPSemconv:   true,
+			EmitMessageBytes: true,
+			MaxBodyLength:    8,
+		},
+		"entry wins": {DuplicateAttributeStrategy: DuplicateEntryWins},
+	}
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			hook, rec := newTestHook(config)
+			hook.telemetry.UpdateContext(map[string]string{"account": "acme", "region": "us-east-1"})
+			hook.SetContextDenylist([]string{"region"})
+			hook.SetRedactKeys([]string{"token"})
+
+			ctx := ContextWithInstanceID(context.Background(), "i-0123456789")
+			entry := &logrus.Entry{
+				Message: "a body long enough to truncate",
+				Context: ctx,
+				Data: logrus.Fields{
+					"status":    503,
+					"api_token": "secret-value",
+					"host.id":   "from-entry",
+					"boot_time": 1500 * time.Millisecond,
+				},
+			}
+
+			described := map[string]interface{}{}
+			for _, kv := range hook.DescribeLogAttributes(entry) {
+				described[string(kv.Key)] = kv.Value.AsInterface()
+			}
+			if err := hook.Fire(entry); err != nil {
+				t.Fatal(err)
+			}
+			emitted := recordAttributes(rec.records[0])
+
+			if len(described) != len(emitted) {
+				t.Errorf("described %d attributes, Fire emitted %d", len(described), len(emitted))
+			}
+			for key, want := range emitted {
+				if got, ok := described[key]; !ok || got != want {
+					t.Errorf("described %s = %v (present=%v), Fire emitted %v", key, got, ok, want)
+				}
+			}
+			// spot-check the shaping really applied.
+			if described["api_token"] != redactedValue {
+				t.Errorf("api_token = %v, want redacted", described["api_token"])
+			}
+			if _, ok := described["region"]; ok {
+				t.Error("region described despite the denylist")
+			}
+		})
+	}
+}
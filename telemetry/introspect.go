@@ -0,0 +1,35 @@
+package telemetry
+
+import "sync/atomic"
+
+// activeComponents counts the live providers across every Telemetry in
+// the process, so tests can assert nothing survived a Shutdown.
+var activeComponents atomic.Int64
+
+// ActiveComponentCount reports the number of live telemetry components
+// — logger, meter, and tracer providers — across all Telemetry
+// instances in the process. Background goroutines are joined before
+// Shutdown returns, so a count back at its pre-Start baseline means no
+// pipeline leaked; tests use this to catch lifecycle bugs such as a
+// Start racing a Shutdown.
+func ActiveComponentCount() int {
+	return int(activeComponents.Load())
+}
+
+// trackComponents reconciles the package-level component count with the
+// providers currently held by this instance. The caller must hold t.mu;
+// call it after any transition that creates or releases providers.
+func (t *Telemetry) trackComponents() {
+	n := 0
+	if t.loggerProvider != nil {
+		n++
+	}
+	if t.meterProvider != nil {
+		n++
+	}
+	if t.tracerProvider != nil {
+		n++
+	}
+	activeComponents.Add(int64(n - t.componentCount))
+	t.componentCount = n
+}
@@ -0,0 +1,41 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestActiveComponentCount(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	baseline := ActiveComponentCount()
+
+	tel := New(Config{
+		Endpoint:     "127.0.0.1:4317",
+		Insecure:     true,
+		ServiceName:  "drone-runner-aws",
+		ExportTraces: true,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := ActiveComponentCount(); got != baseline+3 {
+		t.Errorf("ActiveComponentCount() = %d while running, want %d", got, baseline+3)
+	}
+
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	// the flush to the dead endpoint may error; only the accounting
+	// matters here.
+	tel.Shutdown(shutCtx) //nolint:errcheck
+	if got := ActiveComponentCount(); got != baseline {
+		t.Errorf("ActiveComponentCount() = %d after Shutdown, want baseline %d", got, baseline)
+	}
+
+	// a second Shutdown must not drive the count negative.
+	tel.Shutdown(shutCtx) //nolint:errcheck
+	if got := ActiveComponentCount(); got != baseline {
+		t.Errorf("ActiveComponentCount() = %d after double Shutdown, want %d", got, baseline)
+	}
+}
@@ -0,0 +1,695 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// collectOne builds a meter provider from tel's options around a manual
+// reader, records one increment on counterName, and returns the name of
+// the collected metric.
+func collectOne(t *testing.T, tel *Telemetry, counterName string) string {
+	t.Helper()
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(tel.meterProviderOptions(reader)...)
+	defer provider.Shutdown(ctx) //nolint:errcheck
+
+	counter, err := provider.Meter("test").Int64Counter(counterName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 1)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	if len(rm.ScopeMetrics) != 1 || len(rm.ScopeMetrics[0].Metrics) != 1 {
+		t.Fatalf("unexpected collected shape: %+v", rm.ScopeMetrics)
+	}
+	return rm.ScopeMetrics[0].Metrics[0].Name
+}
+
+// countingMetricExporter counts exports and otherwise behaves as a
+// no-op exporter with default selectors.
+type countingMetricExporter struct {
+	calls int
+}
+
+func (e *countingMetricExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (e *countingMetricExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (e *countingMetricExporter) Export(context.Context, *metricdata.ResourceMetrics) error {
+	e.calls++
+	return nil
+}
+
+func (e *countingMetricExporter) ForceFlush(context.Context) error { return nil }
+func (e *countingMetricExporter) Shutdown(context.Context) error   { return nil }
+
+func TestCollectOnShutdown(t *testing.T) {
+	ctx := context.Background()
+	mock := &countingMetricExporter{}
+	tel := New(Config{})
+	exporter := &gatedMetricExporter{Exporter: mock, dropping: &tel.dropExports, paused: &tel.metricsPaused}
+
+	// enabled (the default): exports pass through.
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	if mock.calls != 1 {
+		t.Errorf("exports = %d, want 1", mock.calls)
+	}
+
+	// disabled: the shutdown path arms the gate and exports are
+	// dropped.
+	tel.config.CollectOnShutdown = boolPtr(false)
+	if tel.config.collectOnShutdown() {
+		t.Fatal("collectOnShutdown() = true with CollectOnShutdown disabled")
+	}
+	tel.dropExports.Store(true)
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	if mock.calls != 1 {
+		t.Errorf("exports after disabling = %d, want 1", mock.calls)
+	}
+}
+
+func TestPauseMetrics(t *testing.T) {
+	ctx := context.Background()
+	mock := &countingMetricExporter{}
+	tel := New(Config{})
+	rec := &recordingLogger{}
+	tel.logger = rec
+	exporter := &gatedMetricExporter{Exporter: mock, dropping: &tel.dropExports, paused: &tel.metricsPaused}
+
+	emit := func() {
+		entry := &logrus.Entry{Message: "pause check", Level: logrus.InfoLevel, Data: logrus.Fields{}}
+		if err := tel.hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// while metrics are paused, exports are withheld but logs flow.
+	tel.PauseMetrics()
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	if mock.calls != 0 {
+		t.Errorf("metric exports during pause = %d, want 0", mock.calls)
+	}
+	emit()
+	if len(rec.records) != 1 {
+		t.Errorf("log records during metrics pause = %d, want 1", len(rec.records))
+	}
+
+	tel.ResumeMetrics()
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	if mock.calls != 1 {
+		t.Errorf("metric exports after resume = %d, want 1", mock.calls)
+	}
+
+	// the log pause is independent of the metrics pause.
+	tel.PauseLogs()
+	emit()
+	if len(rec.records) != 1 {
+		t.Errorf("log records during logs pause = %d, want 1", len(rec.records))
+	}
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	if mock.calls != 2 {
+		t.Errorf("metric exports during logs pause = %d, want 2", mock.calls)
+	}
+	tel.ResumeLogs()
+	emit()
+	if len(rec.records) != 2 {
+		t.Errorf("log records after resume = %d, want 2", len(rec.records))
+	}
+}
+
+func TestShutdownSkipsFinalCollect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:          "127.0.0.1:4317",
+		Insecure:          true,
+		ServiceName:       "drone-runner-aws",
+		CollectOnShutdown: boolPtr(false),
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+	if !tel.dropExports.Load() {
+		t.Error("final collect not skipped with CollectOnShutdown disabled")
+	}
+}
+
+func TestRestartReopensMetricsGate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:          "127.0.0.1:4317",
+		Insecure:          true,
+		ServiceName:       "drone-runner-aws",
+		CollectOnShutdown: boolPtr(false),
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+	if !tel.dropExports.Load() {
+		t.Fatal("final collect not skipped with CollectOnShutdown disabled")
+	}
+
+	// a restart must reopen the gate, or every export of the new
+	// pipeline is silently discarded.
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}()
+	if tel.dropExports.Load() {
+		t.Error("metrics gate still closed after restart")
+	}
+	mock := &countingMetricExporter{}
+	gated := &gatedMetricExporter{Exporter: mock, dropping: &tel.dropExports, paused: &tel.metricsPaused}
+	if err := gated.Export(ctx, &metricdata.ResourceMetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	if mock.calls != 1 {
+		t.Errorf("exports after restart = %d, want 1", mock.calls)
+	}
+}
+
+func TestErrorsTotalCounter(t *testing.T) {
+	ctx := context.Background()
+	tel := New(Config{})
+	rec := &recordingLogger{}
+	tel.logger = rec
+
+	reader := sdkmetric.NewManualReader()
+	tel.meterProvider = sdkmetric.NewMeterProvider(tel.meterProviderOptions(reader)...)
+	defer tel.meterProvider.Shutdown(ctx) //nolint:errcheck
+	if err := tel.registerErrorCounter(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range []logrus.Level{
+		logrus.InfoLevel, logrus.WarnLevel, logrus.ErrorLevel, logrus.FatalLevel,
+	} {
+		entry := &logrus.Entry{Message: "count check", Level: level, Data: logrus.Fields{}}
+		if err := tel.hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "errors_total" {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("unexpected data type %T", m.Data)
+			}
+			for _, dp := range sum.DataPoints {
+				total += dp.Value
+			}
+		}
+	}
+	// only the error and fatal entries count.
+	if total != 2 {
+		t.Errorf("errors_total = %d, want 2", total)
+	}
+}
+
+func TestIngestMetrics(t *testing.T) {
+	ctx := context.Background()
+	tel := New(Config{})
+	rec := &recordingLogger{}
+	tel.logger = rec
+	tel.res = resource.NewSchemaless(attribute.String("service.name", "drone-runner-aws"))
+
+	reader := sdkmetric.NewManualReader()
+	tel.meterProvider = sdkmetric.NewMeterProvider(tel.meterProviderOptions(reader)...)
+	defer tel.meterProvider.Shutdown(ctx) //nolint:errcheck
+	if err := tel.registerIngestMetrics(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []*logrus.Entry{
+		{Message: "hi", Data: logrus.Fields{}},
+		{Message: "a much longer message body", Data: logrus.Fields{
+			"pool": "ubuntu", "id": 42, "zone": "us-east-1a",
+		}},
+	}
+	for _, entry := range entries {
+		if err := tel.hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	histogram := func(name string) metricdata.HistogramDataPoint[int64] {
+		t.Helper()
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != name {
+					continue
+				}
+				hist, ok := m.Data.(metricdata.Histogram[int64])
+				if !ok || len(hist.DataPoints) != 1 {
+					t.Fatalf("unexpected %s data: %+v", name, m.Data)
+				}
+				return hist.DataPoints[0]
+			}
+		}
+		t.Fatalf("histogram %s not collected", name)
+		return metricdata.HistogramDataPoint[int64]{}
+	}
+
+	attrs := histogram("otel.log.record.attributes")
+	if attrs.Count != 2 || attrs.Sum != 3 {
+		t.Errorf("record attribute histogram count/sum = %d/%d, want 2/3", attrs.Count, attrs.Sum)
+	}
+	body := histogram("otel.log.record.body.size")
+	wantSum := int64(len("hi") + len("a much longer message body"))
+	if body.Count != 2 || body.Sum != wantSum {
+		t.Errorf("body size histogram count/sum = %d/%d, want 2/%d", body.Count, body.Sum, wantSum)
+	}
+}
+
+func TestPerReaderTemporality(t *testing.T) {
+	ctx := context.Background()
+	cumulative := sdkmetric.NewManualReader()
+	delta := sdkmetric.NewManualReader(
+		sdkmetric.WithTemporalitySelector(deltaTemporality))
+
+	tel := New(Config{ExtraReaders: []sdkmetric.Reader{delta}})
+	provider := sdkmetric.NewMeterProvider(tel.meterProviderOptions(cumulative)...)
+	defer provider.Shutdown(ctx) //nolint:errcheck
+
+	counter, err := provider.Meter("test").Int64Counter("runner.jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 1)
+
+	collect := func(reader *sdkmetric.ManualReader) metricdata.Temporality {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(ctx, &rm); err != nil {
+			t.Fatal(err)
+		}
+		if len(rm.ScopeMetrics) != 1 || len(rm.ScopeMetrics[0].Metrics) != 1 {
+			t.Fatalf("unexpected collected shape: %+v", rm.ScopeMetrics)
+		}
+		sum, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+		if !ok {
+			t.Fatalf("unexpected data type %T", rm.ScopeMetrics[0].Metrics[0].Data)
+		}
+		return sum.Temporality
+	}
+
+	if got := collect(cumulative); got != metricdata.CumulativeTemporality {
+		t.Errorf("cumulative reader temporality = %v, want cumulative", got)
+	}
+	if got := collect(delta); got != metricdata.DeltaTemporality {
+		t.Errorf("delta reader temporality = %v, want delta", got)
+	}
+}
+
+func TestMetricViews(t *testing.T) {
+	// a user view fully replaces the default for matching instruments.
+	view := sdkmetric.NewView(
+		sdkmetric.Instrument{Name: "runner.builds"},
+		sdkmetric.Stream{Name: "runner.builds.renamed"},
+	)
+	tel := New(Config{MetricViews: []sdkmetric.View{view}})
+	if got := collectOne(t, tel, "runner.builds"); got != "runner.builds.renamed" {
+		t.Errorf("collected metric name = %q, want %q", got, "runner.builds.renamed")
+	}
+
+	// without views the default behavior is preserved.
+	tel = New(Config{})
+	if got := collectOne(t, tel, "runner.builds"); got != "runner.builds" {
+		t.Errorf("collected metric name = %q, want %q", got, "runner.builds")
+	}
+}
+
+func TestRecordQueueWait(t *testing.T) {
+	ctx := context.Background()
+	tel := New(Config{})
+
+	// recording while stopped is a harmless no-op.
+	tel.RecordQueueWait(ctx, time.Second, "pooled")
+
+	reader := sdkmetric.NewManualReader()
+	tel.meterProvider = sdkmetric.NewMeterProvider(tel.meterProviderOptions(reader)...)
+	defer tel.meterProvider.Shutdown(ctx) //nolint:errcheck
+	if err := tel.registerQueueWait(); err != nil {
+		t.Fatal(err)
+	}
+
+	waits := []time.Duration{500 * time.Millisecond, 2 * time.Second, 4 * time.Second}
+	for _, d := range waits {
+		tel.RecordQueueWait(ctx, d, "provisioned")
+	}
+	tel.RecordQueueWait(ctx, time.Second, "pooled")
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	var hist metricdata.Histogram[float64]
+	found := false
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "runner_queue_wait_seconds" {
+				continue
+			}
+			h, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok {
+				t.Fatalf("unexpected data type %T", m.Data)
+			}
+			hist = h
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("runner_queue_wait_seconds not collected")
+	}
+	if len(hist.DataPoints) != 2 {
+		t.Fatalf("data points = %d, want one per status", len(hist.DataPoints))
+	}
+	for _, dp := range hist.DataPoints {
+		status, _ := dp.Attributes.Value("status")
+		switch status.AsString() {
+		case "provisioned":
+			if dp.Count != 3 || dp.Sum != 6.5 {
+				t.Errorf("provisioned count/sum = %d/%v, want 3/6.5", dp.Count, dp.Sum)
+			}
+		case "pooled":
+			if dp.Count != 1 || dp.Sum != 1 {
+				t.Errorf("pooled count/sum = %d/%v, want 1/1", dp.Count, dp.Sum)
+			}
+		default:
+			t.Errorf("unexpected status %q", status.AsString())
+		}
+	}
+}
+
+func TestMetricPrefix(t *testing.T) {
+	tel := New(Config{MetricPrefix: "drone_"})
+	if got := collectOne(t, tel, "runner.builds"); got != "drone_runner.builds" {
+		t.Errorf("collected metric name = %q, want %q", got, "drone_runner.builds")
+	}
+
+	// without a prefix names are untouched.
+	tel = New(Config{})
+	if got := collectOne(t, tel, "runner.builds"); got != "runner.builds" {
+		t.Errorf("collected metric name = %q, want %q", got, "runner.builds")
+	}
+}
+
+func TestReaderConfig(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+	})
+	if got := tel.ReaderConfig(); got != (ReaderConfig{}) {
+		t.Errorf("ReaderConfig() before Start = %+v, want zero", got)
+	}
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := ReaderConfig{Interval: 30 * time.Second, Timeout: 30 * time.Second}
+	if got := tel.ReaderConfig(); got != want {
+		t.Errorf("ReaderConfig() = %+v, want %+v", got, want)
+	}
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+	if got := tel.ReaderConfig(); got != (ReaderConfig{}) {
+		t.Errorf("ReaderConfig() after Shutdown = %+v, want zero", got)
+	}
+
+	// a manual reader has no interval or timeout to report.
+	manual := New(Config{
+		Endpoint:            "127.0.0.1:4317",
+		Insecure:            true,
+		ServiceName:         "drone-runner-aws",
+		ManualMetricsExport: true,
+	})
+	if err := manual.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer manual.Shutdown(ctx) //nolint:errcheck
+	if got := manual.ReaderConfig(); got != (ReaderConfig{}) {
+		t.Errorf("ReaderConfig() with manual reader = %+v, want zero", got)
+	}
+}
+
+// capturingMetricExporter keeps the last exported snapshot.
+type capturingMetricExporter struct {
+	countingMetricExporter
+	last *metricdata.ResourceMetrics
+}
+
+func (e *capturingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.last = rm
+	return e.countingMetricExporter.Export(ctx, rm)
+}
+
+func TestDropEmptyMetrics(t *testing.T) {
+	ctx := context.Background()
+	captured := &capturingMetricExporter{}
+	filter := &emptyFilterMetricExporter{Exporter: captured}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(ctx) //nolint:errcheck
+	meter := provider.Meter("test")
+	active, err := meter.Int64Counter("runner.builds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := meter.Int64Counter("runner.errors"); err != nil {
+		t.Fatal(err)
+	}
+	active.Add(ctx, 1)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	// make the idle counter visible as an empty series, the shape the
+	// filter is there to remove.
+	rm.ScopeMetrics[0].Metrics = append(rm.ScopeMetrics[0].Metrics, metricdata.Metrics{
+		Name: "runner.errors",
+		Data: metricdata.Sum[int64]{},
+	})
+	if err := filter.Export(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	if captured.calls != 1 || captured.last == nil {
+		t.Fatalf("inner exports = %d, want 1", captured.calls)
+	}
+	var names []string
+	for _, sm := range captured.last.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names = append(names, m.Name)
+		}
+	}
+	if len(names) != 1 || names[0] != "runner.builds" {
+		t.Errorf("exported metrics = %v, want only runner.builds", names)
+	}
+
+	// a cycle with nothing but empty series is skipped entirely.
+	empty := metricdata.ResourceMetrics{
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Metrics: []metricdata.Metrics{{Name: "runner.errors", Data: metricdata.Sum[int64]{}}},
+		}},
+	}
+	if err := filter.Export(ctx, &empty); err != nil {
+		t.Fatal(err)
+	}
+	if captured.calls != 1 {
+		t.Errorf("inner exports after empty cycle = %d, want 1", captured.calls)
+	}
+}
+
+func TestPendingReloadGauge(t *testing.T) {
+	ctx := context.Background()
+	tel := New(Config{})
+
+	reader := sdkmetric.NewManualReader()
+	tel.meterProvider = sdkmetric.NewMeterProvider(tel.meterProviderOptions(reader)...)
+	defer tel.meterProvider.Shutdown(ctx) //nolint:errcheck
+	if err := tel.registerPendingReload(); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := func() int64 {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(ctx, &rm); err != nil {
+			t.Fatal(err)
+		}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "otel_config_pending_reload" {
+					continue
+				}
+				data, ok := m.Data.(metricdata.Gauge[int64])
+				if !ok || len(data.DataPoints) != 1 {
+					t.Fatalf("unexpected gauge shape %T %+v", m.Data, m.Data)
+				}
+				return data.DataPoints[0].Value
+			}
+		}
+		t.Fatal("otel_config_pending_reload not collected")
+		return 0
+	}
+
+	if got := pending(); got != 0 {
+		t.Errorf("pending reload gauge = %d, want 0", got)
+	}
+	tel.pendingReload.Store(true)
+	if got := pending(); got != 1 {
+		t.Errorf("pending reload gauge = %d, want 1", got)
+	}
+	tel.pendingReload.Store(false)
+	if got := pending(); got != 0 {
+		t.Errorf("pending reload gauge after clear = %d, want 0", got)
+	}
+}
+
+func TestMetricsExportInterval(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:              "127.0.0.1:4317",
+		Insecure:              true,
+		ServiceName:           "drone-runner-aws",
+		MetricsExportInterval: 10 * time.Second,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := ReaderConfig{Interval: 10 * time.Second, Timeout: 30 * time.Second}
+	if got := tel.ReaderConfig(); got != want {
+		t.Errorf("ReaderConfig() = %+v, want %+v", got, want)
+	}
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+}
+
+// aggCaptureExporter snapshots each instrument's aggregation at export
+// time, since the periodic reader recycles the batch afterwards.
+type aggCaptureExporter struct {
+	countingMetricExporter
+	data map[string]metricdata.Aggregation
+}
+
+func (e *aggCaptureExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			e.data[m.Name] = m.Data
+		}
+	}
+	return e.countingMetricExporter.Export(ctx, rm)
+}
+
+func TestInstrumentAggregationOverride(t *testing.T) {
+	ctx := context.Background()
+	capture := &aggCaptureExporter{data: map[string]metricdata.Aggregation{}}
+	exporter := &reaggregateMetricExporter{
+		Exporter:  capture,
+		overrides: map[string]string{"runner.pool_size": AggregationLastValue},
+	}
+	reader := sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(time.Hour))
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(ctx) //nolint:errcheck
+
+	meter := provider.Meter("test")
+	// pool_size is pushed through a counter by mistake; the override
+	// ships it as a gauge instead.
+	pool, err := meter.Int64Counter("runner.pool_size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Add(ctx, 3)
+	pool.Add(ctx, 5)
+	jobs, err := meter.Int64Counter("runner.jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs.Add(ctx, 3)
+	jobs.Add(ctx, 5)
+	if err := provider.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	gauge, ok := capture.data["runner.pool_size"].(metricdata.Gauge[int64])
+	if !ok {
+		t.Fatalf("runner.pool_size exported as %T, want Gauge[int64]", capture.data["runner.pool_size"])
+	}
+	if len(gauge.DataPoints) != 1 || gauge.DataPoints[0].Value != 8 {
+		t.Errorf("runner.pool_size data points = %+v, want one point of 8", gauge.DataPoints)
+	}
+	// instruments without an override keep their default aggregation.
+	sum, ok := capture.data["runner.jobs"].(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("runner.jobs exported as %T, want Sum[int64]", capture.data["runner.jobs"])
+	}
+	if !sum.IsMonotonic || len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 8 {
+		t.Errorf("runner.jobs data points = %+v, want one monotonic point of 8", sum.DataPoints)
+	}
+
+	bad := Config{Endpoint: "127.0.0.1:4317", InstrumentAggregation: map[string]string{"x": "median"}}
+	if err := bad.Validate(); err == nil {
+		t.Error("Validate() = nil for an unsupported aggregation")
+	}
+}
@@ -0,0 +1,23 @@
+// Package oteltest provides helpers for testing code that wires the
+// telemetry pipeline.
+package oteltest
+
+import (
+	"github.com/drone-runners/drone-runner-aws/telemetry"
+)
+
+// TestConfig returns a telemetry.Config with defaults suitable for
+// unit tests: a local collector endpoint over plaintext gRPC. The
+// functional options mutate the config before it is returned.
+func TestConfig(opts ...func(*telemetry.Config)) telemetry.Config {
+	config := telemetry.Config{
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+		Environment: "test",
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
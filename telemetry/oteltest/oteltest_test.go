@@ -0,0 +1,40 @@
+package oteltest
+
+import (
+	"testing"
+
+	"github.com/drone-runners/drone-runner-aws/telemetry"
+)
+
+func TestTestConfigDefaults(t *testing.T) {
+	config := TestConfig()
+	if config.Endpoint != "127.0.0.1:4317" {
+		t.Errorf("Endpoint = %q, want %q", config.Endpoint, "127.0.0.1:4317")
+	}
+	if !config.Insecure {
+		t.Error("Insecure = false, want true")
+	}
+	if config.ServiceName != "drone-runner-aws" {
+		t.Errorf("ServiceName = %q, want %q", config.ServiceName, "drone-runner-aws")
+	}
+	if config.Environment != "test" {
+		t.Errorf("Environment = %q, want %q", config.Environment, "test")
+	}
+}
+
+func TestTestConfigOptions(t *testing.T) {
+	config := TestConfig(
+		func(c *telemetry.Config) { c.Endpoint = "collector:4317" },
+		func(c *telemetry.Config) { c.LogBodyFormat = telemetry.LogBodyJSON },
+	)
+	if config.Endpoint != "collector:4317" {
+		t.Errorf("Endpoint = %q, want %q", config.Endpoint, "collector:4317")
+	}
+	if config.LogBodyFormat != telemetry.LogBodyJSON {
+		t.Errorf("LogBodyFormat = %q, want %q", config.LogBodyFormat, telemetry.LogBodyJSON)
+	}
+	// unrelated defaults are preserved.
+	if !config.Insecure {
+		t.Error("Insecure = false, want true")
+	}
+}
@@ -0,0 +1,200 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// queueFileGlob matches the journal files of the persistent queue.
+const queueFileGlob = "queue-*.json"
+
+// persistentLogExporter journals every batch to disk before handing it
+// to the wrapped exporter and deletes the journal entry once the export
+// succeeds, giving at-least-once delivery across process crashes.
+// Batches left behind by a previous process are re-exported by replay.
+type persistentLogExporter struct {
+	sdklog.Exporter
+	dir string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// queueLine is the on-disk form of a journaled record.
+type queueLine struct {
+	Time           time.Time              `json:"time"`
+	Severity       string                 `json:"severity"`
+	SeverityNumber int                    `json:"severity_number"`
+	Body           string                 `json:"body"`
+	Attributes     map[string]interface{} `json:"attributes,omitempty"`
+}
+
+func (e *persistentLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	path, journalErr := e.journal(records)
+	if err := e.Exporter.Export(ctx, records); err != nil {
+		// the journal entry stays behind for replay on the next Start.
+		return err
+	}
+	if journalErr != nil {
+		// every record reached the collector, so a journaling failure
+		// must not count the batch as dropped; report it out of band.
+		otel.Handle(journalErr)
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// journal writes the batch to its own journal file and returns its
+// path. File names sort in write order, so replay preserves ordering.
+func (e *persistentLogExporter) journal(records []sdklog.Record) (string, error) {
+	e.mu.Lock()
+	e.seq++
+	path := filepath.Join(e.dir, fmt.Sprintf("queue-%d-%06d.json", time.Now().UnixNano(), e.seq))
+	e.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck
+
+	enc := json.NewEncoder(f)
+	for i := range records {
+		attrs := map[string]interface{}{}
+		records[i].WalkAttributes(func(kv attribute.KeyValue) bool {
+			attrs[string(kv.Key)] = kv.Value.AsInterface()
+			return true
+		})
+		line := queueLine{
+			Time:           records[i].Timestamp(),
+			Severity:       records[i].SeverityText(),
+			SeverityNumber: int(records[i].Severity()),
+			Body:           records[i].Body().Emit(),
+		}
+		if len(attrs) > 0 {
+			line.Attributes = attrs
+		}
+		if err := enc.Encode(line); err != nil {
+			return "", err
+		}
+	}
+	return path, f.Sync()
+}
+
+// replay re-exports every journaled batch left in the queue directory,
+// oldest first, deleting each file once its batch succeeds. It stops at
+// the first failed export so the remaining files survive for the next
+// attempt.
+func (e *persistentLogExporter) replay(ctx context.Context) error {
+	files, err := filepath.Glob(filepath.Join(e.dir, queueFileGlob))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, path := range files {
+		lines, err := decodeQueueFile(path)
+		if err != nil {
+			return fmt.Errorf("telemetry: decode queued records %s: %w", path, err)
+		}
+		if records := mintRecords(lines); len(records) > 0 {
+			if err := e.Exporter.Export(ctx, records); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeQueueFile reads the lines of one journal file.
+func decodeQueueFile(path string) ([]queueLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var lines []queueLine
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var line queueLine
+		if err := dec.Decode(&line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// mintRecords converts decoded journal lines back into SDK records.
+// The records cannot be assembled directly — a zero-value
+// sdklog.Record drops every attribute — so each line is re-emitted
+// through a throwaway logger that applies the SDK's default limits.
+func mintRecords(lines []queueLine) []sdklog.Record {
+	minter := &recordMinter{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(minter))
+	defer provider.Shutdown(context.Background()) //nolint:errcheck
+	logger := provider.Logger("queue-replay")
+	for _, line := range lines {
+		var record log.Record
+		record.SetTimestamp(line.Time)
+		record.SetBody(attribute.StringValue(line.Body))
+		record.SetSeverity(log.Severity(line.SeverityNumber))
+		record.SetSeverityText(line.Severity)
+		for k, v := range line.Attributes {
+			record.AddAttributes(queueAttribute(k, v))
+		}
+		logger.Emit(context.Background(), record)
+	}
+	return minter.records
+}
+
+// recordMinter is the processor behind mintRecords; it keeps a copy of
+// every record the replay logger produces.
+type recordMinter struct {
+	records []sdklog.Record
+}
+
+func (m *recordMinter) Enabled(context.Context, sdklog.EnabledParameters) bool { return true }
+
+func (m *recordMinter) OnEmit(_ context.Context, record *sdklog.Record) error {
+	m.records = append(m.records, record.Clone())
+	return nil
+}
+
+func (m *recordMinter) Shutdown(context.Context) error { return nil }
+
+func (m *recordMinter) ForceFlush(context.Context) error { return nil }
+
+// queueAttribute rebuilds an attribute from its decoded JSON value.
+// Integral numbers round-trip as int64, matching how they were
+// recorded; everything unrecognized degrades to a string.
+func queueAttribute(k string, v interface{}) attribute.KeyValue {
+	switch val := v.(type) {
+	case string:
+		return attribute.String(k, val)
+	case bool:
+		return attribute.Bool(k, val)
+	case float64:
+		if val == math.Trunc(val) {
+			return attribute.Int64(k, int64(val))
+		}
+		return attribute.Float64(k, val)
+	default:
+		return attribute.String(k, fmt.Sprint(val))
+	}
+}
@@ -0,0 +1,123 @@
+package telemetry
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func queueFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, queueFileGlob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestPersistentQueueReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// a failed export leaves its journal entry behind.
+	exporter := &persistentLogExporter{Exporter: &failingLogExporter{}, dir: dir}
+	records := mintRecords([]queueLine{{
+		Time:           time.Now(),
+		Severity:       "error",
+		SeverityNumber: int(log.SeverityError),
+		Body:           "provision failed",
+		Attributes: map[string]interface{}{
+			"pool":    "ubuntu",
+			"retries": float64(3),
+		},
+	}})
+	if err := exporter.Export(ctx, records); err == nil {
+		t.Fatal("Export() = nil, want error")
+	}
+	if got := len(queueFiles(t, dir)); got != 1 {
+		t.Fatalf("journal files after failed export = %d, want 1", got)
+	}
+
+	// a restart reopens the directory and replays the batch.
+	captured := &capturingLogExporter{}
+	reopened := &persistentLogExporter{Exporter: captured, dir: dir}
+	if err := reopened.replay(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(captured.records) != 1 {
+		t.Fatalf("replayed records = %d, want 1", len(captured.records))
+	}
+	replayed := captured.records[0]
+	if got := replayed.Body().Emit(); got != "provision failed" {
+		t.Errorf("body = %q, want %q", got, "provision failed")
+	}
+	if replayed.Severity() != log.SeverityError || replayed.SeverityText() != "error" {
+		t.Errorf("severity = %v/%q, want error", replayed.Severity(), replayed.SeverityText())
+	}
+	attrs := map[string]interface{}{}
+	replayed.WalkAttributes(func(kv attribute.KeyValue) bool {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+		return true
+	})
+	if attrs["pool"] != "ubuntu" {
+		t.Errorf("pool = %v, want ubuntu", attrs["pool"])
+	}
+	if attrs["retries"] != int64(3) {
+		t.Errorf("retries = %v (%T), want 3", attrs["retries"], attrs["retries"])
+	}
+	if got := len(queueFiles(t, dir)); got != 0 {
+		t.Errorf("journal files after replay = %d, want 0", got)
+	}
+
+	// replay with nothing queued is a no-op.
+	if err := reopened.replay(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPersistentQueueDeletesOnSuccess(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	captured := &capturingLogExporter{}
+	exporter := &persistentLogExporter{Exporter: captured, dir: dir}
+
+	var record sdklog.Record
+	record.SetBody(attribute.StringValue("vm ready"))
+	if err := exporter.Export(ctx, []sdklog.Record{record}); err != nil {
+		t.Fatal(err)
+	}
+	if len(captured.records) != 1 {
+		t.Fatalf("exported records = %d, want 1", len(captured.records))
+	}
+	if got := len(queueFiles(t, dir)); got != 0 {
+		t.Errorf("journal files after successful export = %d, want 0", got)
+	}
+}
+
+func TestPersistentQueueReplayStopsOnFailure(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	failing := &persistentLogExporter{Exporter: &failingLogExporter{}, dir: dir}
+	for i := 0; i < 2; i++ {
+		var record sdklog.Record
+		record.SetBody(attribute.StringValue("queued"))
+		failing.Export(ctx, []sdklog.Record{record}) //nolint:errcheck
+	}
+	if got := len(queueFiles(t, dir)); got != 2 {
+		t.Fatalf("journal files = %d, want 2", got)
+	}
+
+	// a still-failing destination keeps the journal intact.
+	if err := failing.replay(ctx); err == nil {
+		t.Fatal("replay() = nil, want error")
+	}
+	if got := len(queueFiles(t, dir)); got != 2 {
+		t.Errorf("journal files after failed replay = %d, want 2", got)
+	}
+}
@@ -0,0 +1,153 @@
+package telemetry
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// prometheusScopeName identifies the bridged metrics' instrumentation
+// scope, so backends can tell them apart from the SDK's own
+// instruments.
+const prometheusScopeName = "github.com/drone-runners/drone-runner-aws/telemetry/prometheus"
+
+// prometheusProducer bridges an existing client_golang registry into
+// the OTEL metrics pipeline. Each collection gathers the registry and
+// converts every family to its metricdata equivalent, so metrics
+// recorded through Prometheus instruments ride the same exporter as
+// the SDK's.
+type prometheusProducer struct {
+	gatherer prometheus.Gatherer
+}
+
+// NewPrometheusProducer returns a metric producer reading the given
+// gatherer — typically a *prometheus.Registry. Attach it to a reader
+// with sdkmetric.WithProducer, or set Config.PrometheusGatherer to
+// bridge into the OTLP reader directly.
+func NewPrometheusProducer(gatherer prometheus.Gatherer) sdkmetric.Producer {
+	return &prometheusProducer{gatherer: gatherer}
+}
+
+// Produce implements sdkmetric.Producer.
+func (p *prometheusProducer) Produce(ctx context.Context) ([]metricdata.ScopeMetrics, error) {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	metrics := make([]metricdata.Metrics, 0, len(families))
+	for _, family := range families {
+		if m, ok := convertPromFamily(family, now); ok {
+			metrics = append(metrics, m)
+		}
+	}
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+	return []metricdata.ScopeMetrics{{
+		Scope:   instrumentation.Scope{Name: prometheusScopeName},
+		Metrics: metrics,
+	}}, nil
+}
+
+// convertPromFamily converts one gathered metric family. The second
+// return is false for family types without a metricdata equivalent.
+func convertPromFamily(family *dto.MetricFamily, now time.Time) (metricdata.Metrics, bool) {
+	out := metricdata.Metrics{Name: family.GetName(), Description: family.GetHelp()}
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		sum := metricdata.Sum[float64]{
+			Temporality: metricdata.CumulativeTemporality,
+			IsMonotonic: true,
+		}
+		for _, m := range family.GetMetric() {
+			sum.DataPoints = append(sum.DataPoints, metricdata.DataPoint[float64]{
+				Attributes: promLabelSet(m.GetLabel()),
+				Time:       now,
+				Value:      m.GetCounter().GetValue(),
+			})
+		}
+		out.Data = sum
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		gauge := metricdata.Gauge[float64]{}
+		for _, m := range family.GetMetric() {
+			value := m.GetGauge().GetValue()
+			if family.GetType() == dto.MetricType_UNTYPED {
+				value = m.GetUntyped().GetValue()
+			}
+			gauge.DataPoints = append(gauge.DataPoints, metricdata.DataPoint[float64]{
+				Attributes: promLabelSet(m.GetLabel()),
+				Time:       now,
+				Value:      value,
+			})
+		}
+		out.Data = gauge
+	case dto.MetricType_HISTOGRAM:
+		hist := metricdata.Histogram[float64]{Temporality: metricdata.CumulativeTemporality}
+		for _, m := range family.GetMetric() {
+			h := m.GetHistogram()
+			// Prometheus buckets carry cumulative counts up to an
+			// inclusive bound; metricdata wants per-bucket counts with
+			// an implicit overflow bucket instead of +Inf.
+			var bounds []float64
+			var counts []uint64
+			var prev uint64
+			for _, b := range h.GetBucket() {
+				if math.IsInf(b.GetUpperBound(), +1) {
+					continue
+				}
+				bounds = append(bounds, b.GetUpperBound())
+				counts = append(counts, b.GetCumulativeCount()-prev)
+				prev = b.GetCumulativeCount()
+			}
+			counts = append(counts, h.GetSampleCount()-prev)
+			hist.DataPoints = append(hist.DataPoints, metricdata.HistogramDataPoint[float64]{
+				Attributes:   promLabelSet(m.GetLabel()),
+				Time:         now,
+				Count:        h.GetSampleCount(),
+				Sum:          h.GetSampleSum(),
+				Bounds:       bounds,
+				BucketCounts: counts,
+			})
+		}
+		out.Data = hist
+	case dto.MetricType_SUMMARY:
+		summary := metricdata.Summary{}
+		for _, m := range family.GetMetric() {
+			s := m.GetSummary()
+			dp := metricdata.SummaryDataPoint{
+				Attributes: promLabelSet(m.GetLabel()),
+				Time:       now,
+				Count:      s.GetSampleCount(),
+				Sum:        s.GetSampleSum(),
+			}
+			for _, q := range s.GetQuantile() {
+				dp.QuantileValues = append(dp.QuantileValues, metricdata.QuantileValue{
+					Quantile: q.GetQuantile(),
+					Value:    q.GetValue(),
+				})
+			}
+			summary.DataPoints = append(summary.DataPoints, dp)
+		}
+		out.Data = summary
+	default:
+		return metricdata.Metrics{}, false
+	}
+	return out, true
+}
+
+// promLabelSet converts Prometheus label pairs to an attribute set.
+func promLabelSet(labels []*dto.LabelPair) attribute.Set {
+	attrs := make([]attribute.KeyValue, 0, len(labels))
+	for _, label := range labels {
+		attrs = append(attrs, attribute.String(label.GetName(), label.GetValue()))
+	}
+	return attribute.NewSet(attrs...)
+}
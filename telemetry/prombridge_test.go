@@ -0,0 +1,124 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestPrometheusProducer(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobs_completed_total",
+		Help: "Jobs completed by the runner.",
+	}, []string{"pool"})
+	registry.MustRegister(counter)
+	counter.WithLabelValues("ubuntu").Add(3)
+
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "provision_seconds",
+		Help:    "Time to provision an instance.",
+		Buckets: []float64{1, 5},
+	})
+	registry.MustRegister(histogram)
+	histogram.Observe(0.5)
+	histogram.Observe(2)
+	histogram.Observe(30)
+
+	reader := sdkmetric.NewManualReader(sdkmetric.WithProducer(NewPrometheusProducer(registry)))
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(ctx) //nolint:errcheck
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]metricdata.Metrics{}
+	for _, sm := range rm.ScopeMetrics {
+		if sm.Scope.Name != prometheusScopeName {
+			continue
+		}
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+
+	jobs, ok := metrics["jobs_completed_total"]
+	if !ok {
+		t.Fatal("bridged counter not collected")
+	}
+	sum, ok := jobs.Data.(metricdata.Sum[float64])
+	if !ok || !sum.IsMonotonic {
+		t.Fatalf("counter converted to %T, want monotonic Sum[float64]", jobs.Data)
+	}
+	if len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 3 {
+		t.Errorf("counter data points = %+v, want one point of 3", sum.DataPoints)
+	}
+	if v, ok := sum.DataPoints[0].Attributes.Value("pool"); !ok || v.AsString() != "ubuntu" {
+		t.Errorf("counter missing pool label, attributes = %v", sum.DataPoints[0].Attributes)
+	}
+
+	prov, ok := metrics["provision_seconds"]
+	if !ok {
+		t.Fatal("bridged histogram not collected")
+	}
+	hist, ok := prov.Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("histogram converted to %T", prov.Data)
+	}
+	dp := hist.DataPoints[0]
+	if dp.Count != 3 || dp.Sum != 32.5 {
+		t.Errorf("histogram count=%d sum=%v, want 3 and 32.5", dp.Count, dp.Sum)
+	}
+	// the cumulative bucket counts de-cumulate, with the +Inf bucket
+	// becoming the implicit overflow bucket.
+	if len(dp.Bounds) != 2 || dp.Bounds[0] != 1 || dp.Bounds[1] != 5 {
+		t.Errorf("bounds = %v, want [1 5]", dp.Bounds)
+	}
+	if len(dp.BucketCounts) != 3 || dp.BucketCounts[0] != 1 || dp.BucketCounts[1] != 1 || dp.BucketCounts[2] != 1 {
+		t.Errorf("bucket counts = %v, want [1 1 1]", dp.BucketCounts)
+	}
+}
+
+func TestPrometheusGathererConfig(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "bridge_checks_total"})
+	registry.MustRegister(counter)
+	counter.Inc()
+
+	tel := New(Config{
+		Endpoint:            "127.0.0.1:4317",
+		Insecure:            true,
+		ServiceName:         "drone-runner-aws",
+		ManualMetricsExport: true,
+		PrometheusGatherer:  registry,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}()
+
+	var rm metricdata.ResourceMetrics
+	if err := tel.manualReader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "bridge_checks_total" {
+				return
+			}
+		}
+	}
+	t.Error("bridged metric missing from the configured reader's collection")
+}
@@ -0,0 +1,210 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// remoteWriteTimeout bounds a single remote-write push.
+const remoteWriteTimeout = 30 * time.Second
+
+// remoteWriteExporter pushes collected metrics to a Prometheus
+// remote-write endpoint, for deployments that have no OTLP collector.
+// Sums and gauges are exported as individual time series; other
+// instrument types are skipped. The protobuf WriteRequest is encoded
+// by hand, so the exporter does not pull in the prometheus module.
+type remoteWriteExporter struct {
+	url    string
+	client *http.Client
+}
+
+// newRemoteWriteExporter returns an exporter pushing to the given
+// remote-write URL.
+func newRemoteWriteExporter(url string) *remoteWriteExporter {
+	return &remoteWriteExporter{
+		url:    url,
+		client: &http.Client{Timeout: remoteWriteTimeout},
+	}
+}
+
+// Temporality implements sdkmetric.Exporter. Remote-write carries
+// cumulative series only.
+func (e *remoteWriteExporter) Temporality(sdkmetric.InstrumentKind) metricdata.Temporality {
+	return metricdata.CumulativeTemporality
+}
+
+// Aggregation implements sdkmetric.Exporter.
+func (e *remoteWriteExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+// Export implements sdkmetric.Exporter.
+func (e *remoteWriteExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	body := encodeWriteRequest(rm)
+	if len(body) == 0 {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url,
+		bytes.NewReader(snappy.Encode(nil, body)))
+	if err != nil {
+		return fmt.Errorf("telemetry: remote write: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telemetry: remote write: %w", err)
+	}
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry: remote write: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ForceFlush implements sdkmetric.Exporter. Pushes are synchronous, so
+// there is nothing to flush.
+func (e *remoteWriteExporter) ForceFlush(context.Context) error { return nil }
+
+// Shutdown implements sdkmetric.Exporter.
+func (e *remoteWriteExporter) Shutdown(context.Context) error { return nil }
+
+// encodeWriteRequest renders the sums and gauges of rm as a protobuf
+// WriteRequest (field 1: repeated TimeSeries).
+func encodeWriteRequest(rm *metricdata.ResourceMetrics) []byte {
+	var out []byte
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			name := sanitizeMetricName(m.Name)
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					out = appendMessage(out, 1, encodeTimeSeries(name, dp.Attributes, float64(dp.Value), dp.Time))
+				}
+			case metricdata.Gauge[float64]:
+				for _, dp := range data.DataPoints {
+					out = appendMessage(out, 1, encodeTimeSeries(name, dp.Attributes, dp.Value, dp.Time))
+				}
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					out = appendMessage(out, 1, encodeTimeSeries(name, dp.Attributes, float64(dp.Value), dp.Time))
+				}
+			case metricdata.Sum[float64]:
+				for _, dp := range data.DataPoints {
+					out = appendMessage(out, 1, encodeTimeSeries(name, dp.Attributes, dp.Value, dp.Time))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// encodeTimeSeries renders one TimeSeries message: repeated Label
+// (field 1) sorted by name, followed by one Sample (field 2).
+func encodeTimeSeries(name string, attrs attribute.Set, value float64, ts time.Time) []byte {
+	labels := []string{"__name__", name}
+	for _, kv := range attrs.ToSlice() {
+		labels = append(labels, sanitizeLabelName(string(kv.Key)), kv.Value.Emit())
+	}
+	sort.Sort(labelPairs(labels))
+
+	var series []byte
+	for i := 0; i < len(labels); i += 2 {
+		var label []byte
+		label = appendString(label, 1, labels[i])
+		label = appendString(label, 2, labels[i+1])
+		series = appendMessage(series, 1, label)
+	}
+	var sample []byte
+	sample = appendDouble(sample, 1, value)
+	sample = appendVarintField(sample, 2, uint64(ts.UnixMilli()))
+	return appendMessage(series, 2, sample)
+}
+
+// labelPairs sorts a flat name/value slice by label name.
+type labelPairs []string
+
+func (p labelPairs) Len() int           { return len(p) / 2 }
+func (p labelPairs) Less(i, j int) bool { return p[2*i] < p[2*j] }
+func (p labelPairs) Swap(i, j int) {
+	p[2*i], p[2*j] = p[2*j], p[2*i]
+	p[2*i+1], p[2*j+1] = p[2*j+1], p[2*i+1]
+}
+
+// sanitizeMetricName maps a metric name onto the prometheus character
+// set, replacing every invalid rune with an underscore.
+func sanitizeMetricName(name string) string {
+	return sanitize(name, func(r rune, first bool) bool {
+		return r == '_' || r == ':' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(!first && r >= '0' && r <= '9')
+	})
+}
+
+// sanitizeLabelName maps a label name onto the prometheus character
+// set.
+func sanitizeLabelName(name string) string {
+	return sanitize(name, func(r rune, first bool) bool {
+		return r == '_' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(!first && r >= '0' && r <= '9')
+	})
+}
+
+func sanitize(name string, valid func(r rune, first bool) bool) string {
+	out := []rune(name)
+	for i, r := range out {
+		if !valid(r, i == 0) {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
+
+// appendVarint appends the protobuf varint encoding of v.
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendMessage appends a length-delimited field.
+func appendMessage(b []byte, field int, msg []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|2)
+	b = appendVarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+// appendString appends a string field.
+func appendString(b []byte, field int, s string) []byte {
+	return appendMessage(b, field, []byte(s))
+}
+
+// appendDouble appends a fixed64 double field.
+func appendDouble(b []byte, field int, v float64) []byte {
+	b = appendVarint(b, uint64(field)<<3|1)
+	bits := math.Float64bits(v)
+	for i := 0; i < 8; i++ {
+		b = append(b, byte(bits>>(8*i)))
+	}
+	return b
+}
+
+// appendVarintField appends a varint field.
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = appendVarint(b, uint64(field)<<3|0)
+	return appendVarint(b, v)
+}
@@ -0,0 +1,242 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// remoteWriteReceiver is a stub remote-write endpoint capturing the
+// decompressed request bodies.
+type remoteWriteReceiver struct {
+	bodies chan []byte
+}
+
+func (r *remoteWriteReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	compressed, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	select {
+	case r.bodies <- body:
+	default:
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestRemoteWriteExport(t *testing.T) {
+	receiver := &remoteWriteReceiver{bodies: make(chan []byte, 4)}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	ctx := context.Background()
+	exporter := newRemoteWriteExporter(server.URL)
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(
+		sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(time.Hour))))
+	defer provider.Shutdown(ctx) //nolint:errcheck
+
+	counter, err := provider.Meter("test").Int64Counter("runner.jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 3, metric.WithAttributes(attribute.String("pool", "ubuntu")))
+	if err := provider.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case body := <-receiver.bodies:
+		// the sanitized metric name and the datapoint labels appear
+		// verbatim in the protobuf payload.
+		for _, want := range []string{"__name__", "runner_jobs", "pool", "ubuntu"} {
+			if !bytes.Contains(body, []byte(want)) {
+				t.Errorf("remote-write payload missing %q", want)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no remote-write push received")
+	}
+}
+
+func TestRemoteWritePipeline(t *testing.T) {
+	receiver := &remoteWriteReceiver{bodies: make(chan []byte, 4)}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	tel := New(Config{
+		Endpoint:       "127.0.0.1:4317",
+		Insecure:       true,
+		ServiceName:    "drone-runner-aws",
+		MetricsFormat:  MetricsFormatPrometheusRemoteWrite,
+		RemoteWriteURL: server.URL,
+	})
+	if err := tel.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		tel.Shutdown(ctx) //nolint:errcheck
+	}()
+
+	if err := tel.meterProvider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-receiver.bodies:
+		if !bytes.Contains(body, []byte("runner_build_info")) {
+			t.Error("remote-write payload missing the build info gauge")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no remote-write push received")
+	}
+}
+
+func TestManualMetricsExport(t *testing.T) {
+	receiver := &remoteWriteReceiver{bodies: make(chan []byte, 4)}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	tel := New(Config{
+		Endpoint:            "127.0.0.1:4317",
+		Insecure:            true,
+		ServiceName:         "drone-runner-aws",
+		MetricsFormat:       MetricsFormatPrometheusRemoteWrite,
+		RemoteWriteURL:      server.URL,
+		ManualMetricsExport: true,
+	})
+	if err := tel.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		tel.Shutdown(ctx) //nolint:errcheck
+	}()
+
+	counter, err := tel.meterProvider.Meter("test").Int64Counter("runner.jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(context.Background(), 1)
+
+	// without an explicit collect nothing is exported.
+	select {
+	case <-receiver.bodies:
+		t.Fatal("automatic export in manual mode")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	if err := tel.CollectMetrics(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-receiver.bodies:
+		if !bytes.Contains(body, []byte("runner_jobs")) {
+			t.Error("manual collect payload missing the recorded counter")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no export after CollectMetrics")
+	}
+
+	// outside manual mode the explicit collect is rejected.
+	if err := New(Config{}).CollectMetrics(context.Background()); err == nil {
+		t.Error("CollectMetrics succeeded without ManualMetricsExport")
+	}
+}
+
+func TestSanitizeMetricName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"runner.jobs", "runner_jobs"},
+		{"otel.collector.connection.transitions", "otel_collector_connection_transitions"},
+		{"0abc", "_abc"},
+		{"a:b", "a:b"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeMetricName(tt.in); got != tt.want {
+			t.Errorf("sanitizeMetricName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReconfigureDrainsMetrics(t *testing.T) {
+	oldReceiver := &remoteWriteReceiver{bodies: make(chan []byte, 4)}
+	oldServer := httptest.NewServer(oldReceiver)
+	defer oldServer.Close()
+	newReceiver := &remoteWriteReceiver{bodies: make(chan []byte, 4)}
+	newServer := httptest.NewServer(newReceiver)
+	defer newServer.Close()
+
+	tel := New(Config{
+		Endpoint:       "127.0.0.1:4317",
+		Insecure:       true,
+		ServiceName:    "drone-runner-aws",
+		MetricsFormat:  MetricsFormatPrometheusRemoteWrite,
+		RemoteWriteURL: oldServer.URL,
+	})
+	if err := tel.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		tel.Shutdown(ctx) //nolint:errcheck
+	}()
+
+	counter, err := tel.meterProvider.Meter("test").Int64Counter("runner.jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(context.Background(), 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	config := tel.ActiveConfig()
+	config.RemoteWriteURL = newServer.URL
+	if err := tel.Reconfigure(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	// the accumulated window drained to the old endpoint during the
+	// switch, and nothing has reached the new one yet.
+	select {
+	case body := <-oldReceiver.bodies:
+		if !bytes.Contains(body, []byte("runner_jobs")) {
+			t.Error("drained payload missing the recorded counter")
+		}
+	default:
+		t.Error("no drain export to the old endpoint")
+	}
+	select {
+	case <-newReceiver.bodies:
+		t.Error("new endpoint received an export before the switch completed")
+	default:
+	}
+
+	// post-switch flushes reach the new endpoint only.
+	if err := tel.meterProvider.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-newReceiver.bodies:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no export to the new endpoint after Reconfigure")
+	}
+}
@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// rampExportCount is how many exports are staggered after a reconnect
+// before full-rate export resumes.
+const rampExportCount = 3
+
+// rampGate staggers the first few exports after the collector
+// connection recovers from an outage, so a queued backlog does not
+// overwhelm the collector the moment it comes back.
+type rampGate struct {
+	mu        sync.Mutex
+	delay     time.Duration
+	remaining int
+}
+
+// trip arms the gate; the next few exports will each be delayed.
+func (g *rampGate) trip() {
+	g.mu.Lock()
+	g.remaining = rampExportCount
+	g.mu.Unlock()
+}
+
+// wait blocks for the ramp delay while the gate is armed, consuming one
+// of the staggered slots. It returns immediately at full rate.
+func (g *rampGate) wait(ctx context.Context) {
+	g.mu.Lock()
+	if g.remaining <= 0 {
+		g.mu.Unlock()
+		return
+	}
+	g.remaining--
+	delay := g.delay
+	g.mu.Unlock()
+	select {
+	case <-ctx.Done():
+	case <-time.After(delay):
+	}
+}
+
+// rampLogExporter applies the post-reconnect ramp to log exports.
+type rampLogExporter struct {
+	sdklog.Exporter
+	gate *rampGate
+}
+
+func (e *rampLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	e.gate.wait(ctx)
+	return e.Exporter.Export(ctx, records)
+}
+
+// rampMetricExporter applies the post-reconnect ramp to metric exports.
+type rampMetricExporter struct {
+	sdkmetric.Exporter
+	gate *rampGate
+}
+
+func (e *rampMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.gate.wait(ctx)
+	return e.Exporter.Export(ctx, rm)
+}
@@ -0,0 +1,59 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// countingLogExporter records export call times.
+type countingLogExporter struct {
+	at []time.Time
+}
+
+func (e *countingLogExporter) Export(context.Context, []sdklog.Record) error {
+	e.at = append(e.at, time.Now())
+	return nil
+}
+
+func (e *countingLogExporter) Shutdown(context.Context) error   { return nil }
+func (e *countingLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestRampGate(t *testing.T) {
+	gate := &rampGate{delay: 50 * time.Millisecond}
+	mock := &countingLogExporter{}
+	exporter := &rampLogExporter{Exporter: mock, gate: gate}
+	ctx := context.Background()
+
+	// before any reconnect the gate is open.
+	started := time.Now()
+	if err := exporter.Export(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed >= 50*time.Millisecond {
+		t.Errorf("export before reconnect delayed by %v", elapsed)
+	}
+
+	// after a reconnect the first few exports are staggered.
+	gate.trip()
+	started = time.Now()
+	for i := 0; i < rampExportCount; i++ {
+		if err := exporter.Export(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < time.Duration(rampExportCount)*50*time.Millisecond {
+		t.Errorf("ramped exports took %v, want at least %v", elapsed, rampExportCount*50*time.Millisecond)
+	}
+
+	// full rate resumes once the ramp slots are consumed.
+	started = time.Now()
+	if err := exporter.Export(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed >= 50*time.Millisecond {
+		t.Errorf("export after ramp delayed by %v", elapsed)
+	}
+}
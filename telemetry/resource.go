@@ -0,0 +1,122 @@
+package telemetry
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// NewResource builds the resource attached to exports for the given
+// configuration. An enabled configuration runs the host and OS
+// detectors; a disabled one short-circuits to the minimal identity
+// attributes, so it never pays for detection work.
+func NewResource(ctx context.Context, config Config) (*resource.Resource, error) {
+	opts := []resource.Option{
+		resource.WithAttributes(
+			semconv.ServiceName(config.ServiceName),
+			semconv.ServiceVersion(Version()),
+			semconv.DeploymentEnvironmentName(config.Environment),
+		),
+	}
+	if config.InstanceType != "" {
+		opts = append(opts, resource.WithAttributes(semconv.HostType(config.InstanceType)))
+	}
+	if config.enabled() {
+		opts = append(opts, resource.WithHost(), resource.WithOS())
+	}
+	res, err := resource.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if config.DetectEC2 {
+		detected, detectErr := NewEC2Detector().Detect(ctx)
+		if detectErr != nil {
+			logrus.WithError(detectErr).Warning("telemetry: EC2 metadata unavailable, continuing without cloud attributes")
+		} else if res, err = resource.Merge(res, detected); err != nil {
+			return nil, err
+		}
+	}
+	if config.BaseResource != nil {
+		res, err = resource.Merge(config.BaseResource, res)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// re-stamp rather than resource.WithSchemaURL, which would conflict
+	// with the schema URL the built-in detectors already carry.
+	if config.SchemaURL != "" {
+		res = resource.NewWithAttributes(config.SchemaURL, res.Attributes()...)
+	}
+	return res, nil
+}
+
+// capResourceAttributes returns a resource truncated to at most max
+// attributes. Priority keys (service.* and the deployment environment)
+// are always kept, even past the limit; the remainder fills up to max
+// in the set's key order, so truncation is deterministic. A max of
+// zero disables the cap.
+func capResourceAttributes(res *resource.Resource, max int) *resource.Resource {
+	attrs := res.Attributes()
+	if max <= 0 || len(attrs) <= max {
+		return res
+	}
+	kept := make([]attribute.KeyValue, 0, max)
+	var rest []attribute.KeyValue
+	for _, kv := range attrs {
+		if priorityResourceKey(string(kv.Key)) {
+			kept = append(kept, kv)
+		} else {
+			rest = append(rest, kv)
+		}
+	}
+	for _, kv := range rest {
+		if len(kept) >= max {
+			break
+		}
+		kept = append(kept, kv)
+	}
+	return resource.NewWithAttributes(res.SchemaURL(), kept...)
+}
+
+// priorityResourceKey reports whether the resource attribute key is
+// exempt from truncation.
+func priorityResourceKey(key string) bool {
+	return strings.HasPrefix(key, "service.") ||
+		strings.HasPrefix(key, "deployment.environment")
+}
+
+// detectResources runs the given detectors in the background and, once
+// they return, merges the detected attributes into the resource and
+// rebuilds the providers so later exports carry them. Detection errors
+// are reported through the otel error handler; partial results are
+// still merged.
+func (t *Telemetry) detectResources(ctx context.Context, detectors []resource.Detector) {
+	defer t.wg.Done()
+	detected, err := resource.Detect(ctx, detectors...)
+	if err != nil {
+		otel.Handle(err)
+	}
+	if detected == nil || len(detected.Attributes()) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.started {
+		return
+	}
+	merged, err := resource.Merge(t.res, detected)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	t.res = capResourceAttributes(merged, t.config.MaxResourceAttributes)
+	if err := t.buildProviders(ctx, t.headers); err != nil {
+		otel.Handle(err)
+	}
+}
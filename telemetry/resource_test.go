@@ -0,0 +1,267 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// gatedDetector blocks until released, simulating metadata that only
+// becomes available after Start.
+type gatedDetector struct {
+	ready chan struct{}
+	attrs []attribute.KeyValue
+}
+
+func (d *gatedDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	select {
+	case <-d.ready:
+		return resource.NewSchemaless(d.attrs...), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestNewResourceEnabledGate(t *testing.T) {
+	ctx := context.Background()
+
+	// a disabled configuration yields only the identity attributes.
+	minimal, err := NewResource(ctx, Config{ServiceName: "drone-runner-aws", Enabled: boolPtr(false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(minimal.Attributes()); got != 3 {
+		t.Errorf("disabled resource attribute count = %d, want 3", got)
+	}
+
+	// an enabled one runs the host and OS detectors.
+	full, err := NewResource(ctx, Config{ServiceName: "drone-runner-aws"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := full.Set().Value("host.name"); !ok {
+		t.Error("enabled resource missing host.name")
+	}
+	if _, ok := full.Set().Value("os.type"); !ok {
+		t.Error("enabled resource missing os.type")
+	}
+
+	// a disabled pipeline never starts.
+	tel := New(Config{Endpoint: "127.0.0.1:4317", Insecure: true, Enabled: boolPtr(false)})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if tel.emitLogger() != nil {
+		t.Error("pipeline live despite Enabled=false")
+	}
+}
+
+func TestResourceSchemaURL(t *testing.T) {
+	ctx := context.Background()
+
+	const schema = "https://opentelemetry.io/schemas/1.24.0"
+	res, err := NewResource(ctx, Config{ServiceName: "drone-runner-aws", SchemaURL: schema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.SchemaURL(); got != schema {
+		t.Errorf("SchemaURL() = %q, want %q", got, schema)
+	}
+
+	// without an override the semconv default is kept.
+	res, err = NewResource(ctx, Config{ServiceName: "drone-runner-aws"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.SchemaURL(); got == "" || got == schema {
+		t.Errorf("default SchemaURL() = %q, want the semconv default", got)
+	}
+}
+
+func TestCapResourceAttributes(t *testing.T) {
+	res := resource.NewSchemaless(
+		attribute.String("cloud.region", "us-east-1"),
+		attribute.String("host.id", "i-0123456789"),
+		attribute.String("host.image.id", "ami-abc"),
+		attribute.String("host.type", "m5.large"),
+		attribute.String("service.name", "drone-runner-aws"),
+	)
+
+	capped := capResourceAttributes(res, 3)
+	set := capped.Set()
+	if got := len(capped.Attributes()); got != 3 {
+		t.Errorf("capped attribute count = %d, want 3", got)
+	}
+	if _, ok := set.Value("service.name"); !ok {
+		t.Error("service.name dropped by truncation")
+	}
+
+	// priority keys survive even when they alone exceed the cap.
+	res = resource.NewSchemaless(
+		attribute.String("service.name", "drone-runner-aws"),
+		attribute.String("service.version", "1.0"),
+		attribute.String("deployment.environment.name", "prod"),
+	)
+	capped = capResourceAttributes(res, 1)
+	if got := len(capped.Attributes()); got != 3 {
+		t.Errorf("priority attribute count = %d, want 3", got)
+	}
+
+	// a zero cap leaves the resource untouched.
+	if capResourceAttributes(res, 0) != res {
+		t.Error("zero cap rebuilt the resource")
+	}
+}
+
+func TestLazyResourceDetection(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	detector := &gatedDetector{
+		ready: make(chan struct{}),
+		attrs: []attribute.KeyValue{attribute.String("host.id", "i-0123456789")},
+	}
+	tel := New(Config{
+		Endpoint:              "127.0.0.1:4317",
+		Insecure:              true,
+		ServiceName:           "drone-runner-aws",
+		LazyResourceDetectors: []resource.Detector{detector},
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}()
+
+	hostID := func() (attribute.Value, bool) {
+		res := tel.GetResource()
+		if res == nil {
+			return attribute.Value{}, false
+		}
+		return res.Set().Value("host.id")
+	}
+	if _, ok := hostID(); ok {
+		t.Fatal("host.id present before the detector is ready")
+	}
+
+	close(detector.ready)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if v, ok := hostID(); ok {
+			if v.AsString() != "i-0123456789" {
+				t.Fatalf("host.id = %q, want %q", v.AsString(), "i-0123456789")
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("host.id never merged into the resource")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestResourceInstanceType(t *testing.T) {
+	ctx := context.Background()
+
+	res, err := NewResource(ctx, Config{ServiceName: "drone-runner-aws", InstanceType: "m5.large"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ok := res.Set().Value("host.type")
+	if !ok {
+		t.Fatal("resource missing host.type")
+	}
+	if v.AsString() != "m5.large" {
+		t.Errorf("host.type = %q, want m5.large", v.AsString())
+	}
+
+	// without the option no host.type is reported.
+	res, err = NewResource(ctx, Config{ServiceName: "drone-runner-aws"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res.Set().Value("host.type"); ok {
+		t.Error("host.type present without InstanceType")
+	}
+}
+
+func TestBaseResourceMerge(t *testing.T) {
+	ctx := context.Background()
+
+	base := resource.NewSchemaless(
+		attribute.String("team", "ci"),
+		attribute.String("service.name", "their-service"),
+	)
+	res, err := NewResource(ctx, Config{ServiceName: "drone-runner-aws", BaseResource: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// base-only attributes are kept.
+	v, ok := res.Set().Value("team")
+	if !ok || v.AsString() != "ci" {
+		t.Errorf("team = %v/%v, want ci/true", v.AsString(), ok)
+	}
+	// on conflict this package's attributes win.
+	v, ok = res.Set().Value("service.name")
+	if !ok || v.AsString() != "drone-runner-aws" {
+		t.Errorf("service.name = %v/%v, want drone-runner-aws/true", v.AsString(), ok)
+	}
+	// detected attributes still appear alongside the base.
+	if _, ok := res.Set().Value("host.name"); !ok {
+		t.Error("merged resource missing host.name")
+	}
+}
+
+func TestResourceServiceVersion(t *testing.T) {
+	ctx := context.Background()
+
+	res, err := NewResource(ctx, Config{ServiceName: "drone-runner-aws"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ok := res.Set().Value("service.version")
+	if !ok {
+		t.Fatal("resource missing service.version")
+	}
+	if v.AsString() != Version() {
+		t.Errorf("service.version = %q, want %q", v.AsString(), Version())
+	}
+}
+
+func TestPipelineStartTimeAttribute(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	before := time.Now().Add(-time.Second)
+	tel := New(Config{
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}()
+
+	value, ok := tel.res.Set().Value("otel.pipeline.start_time")
+	if !ok {
+		t.Fatal("otel.pipeline.start_time missing from the resource")
+	}
+	started, err := time.Parse(time.RFC3339Nano, value.AsString())
+	if err != nil {
+		t.Fatalf("otel.pipeline.start_time %q not parseable: %v", value.AsString(), err)
+	}
+	if started.Before(before) || started.After(time.Now()) {
+		t.Errorf("otel.pipeline.start_time = %v, want between %v and now", started, before)
+	}
+}
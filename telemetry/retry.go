@@ -0,0 +1,218 @@
+package telemetry
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// retryDelay is the initial backoff between bounded retry attempts.
+const retryDelay = 100 * time.Millisecond
+
+// withRetries runs fn once and retries it up to the given number of
+// additional times with exponential backoff, honoring ctx cancellation.
+// The last error is returned when all attempts fail.
+func withRetries(ctx context.Context, retries int, fn func() error) error {
+	var err error
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || attempt == retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// gatedMetricExporter silently drops exports while either gate is set,
+// used to skip the final shutdown collection when CollectOnShutdown is
+// disabled and to withhold metrics during a PauseMetrics window.
+type gatedMetricExporter struct {
+	sdkmetric.Exporter
+	dropping *atomic.Bool
+	paused   *atomic.Bool
+	ready    func()
+	results  *exportWindow
+}
+
+func (e *gatedMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if e.dropping.Load() || e.paused.Load() {
+		return nil
+	}
+	if err := e.Exporter.Export(ctx, rm); err != nil {
+		if e.results != nil {
+			e.results.record(false)
+		}
+		return err
+	}
+	if e.results != nil {
+		e.results.record(true)
+	}
+	if e.ready != nil {
+		e.ready()
+	}
+	return nil
+}
+
+// reaggregateMetricExporter rewrites the aggregation of named
+// instruments in each export, converting between sum and last-value,
+// for values recorded through the wrong instrument kind. The SDK
+// refuses a last-value view on a counter, so the conversion happens on
+// the collected data instead: a counter overridden to last-value ships
+// its accumulated total as a gauge point.
+type reaggregateMetricExporter struct {
+	sdkmetric.Exporter
+	overrides map[string]string
+}
+
+func (e *reaggregateMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	for si, sm := range rm.ScopeMetrics {
+		for mi, m := range sm.Metrics {
+			if agg, ok := e.overrides[m.Name]; ok {
+				rm.ScopeMetrics[si].Metrics[mi].Data = reaggregate(m.Data, agg)
+			}
+		}
+	}
+	return e.Exporter.Export(ctx, rm)
+}
+
+// reaggregate converts one aggregation to the requested kind.
+// Combinations with nothing to convert — a sum already being a sum, or
+// an aggregation with no counterpart — pass through unchanged.
+func reaggregate(data metricdata.Aggregation, agg string) metricdata.Aggregation {
+	switch agg {
+	case AggregationLastValue:
+		switch a := data.(type) {
+		case metricdata.Sum[int64]:
+			return metricdata.Gauge[int64]{DataPoints: a.DataPoints}
+		case metricdata.Sum[float64]:
+			return metricdata.Gauge[float64]{DataPoints: a.DataPoints}
+		}
+	case AggregationSum:
+		switch a := data.(type) {
+		case metricdata.Gauge[int64]:
+			return metricdata.Sum[int64]{Temporality: metricdata.CumulativeTemporality, DataPoints: a.DataPoints}
+		case metricdata.Gauge[float64]:
+			return metricdata.Sum[float64]{Temporality: metricdata.CumulativeTemporality, DataPoints: a.DataPoints}
+		}
+	}
+	return data
+}
+
+// emptyFilterMetricExporter removes instruments that collected no data
+// points in the current cycle, so instruments that are created but
+// never recorded do not appear as empty series in every export. An
+// export whose metrics are all empty is skipped entirely.
+type emptyFilterMetricExporter struct {
+	sdkmetric.Exporter
+}
+
+func (e *emptyFilterMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	scopes := rm.ScopeMetrics[:0]
+	for _, sm := range rm.ScopeMetrics {
+		metrics := sm.Metrics[:0]
+		for _, m := range sm.Metrics {
+			if metricDataPoints(m.Data) > 0 {
+				metrics = append(metrics, m)
+			}
+		}
+		sm.Metrics = metrics
+		if len(sm.Metrics) > 0 {
+			scopes = append(scopes, sm)
+		}
+	}
+	rm.ScopeMetrics = scopes
+	if len(rm.ScopeMetrics) == 0 {
+		return nil
+	}
+	return e.Exporter.Export(ctx, rm)
+}
+
+// metricDataPoints counts the data points of an aggregation. Unknown
+// aggregation kinds report one so they are never filtered out.
+func metricDataPoints(data metricdata.Aggregation) int {
+	switch a := data.(type) {
+	case metricdata.Sum[int64]:
+		return len(a.DataPoints)
+	case metricdata.Sum[float64]:
+		return len(a.DataPoints)
+	case metricdata.Gauge[int64]:
+		return len(a.DataPoints)
+	case metricdata.Gauge[float64]:
+		return len(a.DataPoints)
+	case metricdata.Histogram[int64]:
+		return len(a.DataPoints)
+	case metricdata.Histogram[float64]:
+		return len(a.DataPoints)
+	case metricdata.ExponentialHistogram[int64]:
+		return len(a.DataPoints)
+	case metricdata.ExponentialHistogram[float64]:
+		return len(a.DataPoints)
+	case metricdata.Summary:
+		return len(a.DataPoints)
+	default:
+		return 1
+	}
+}
+
+// accountingLogExporter counts how many records were exported versus
+// dropped, feeding the shutdown report.
+type accountingLogExporter struct {
+	sdklog.Exporter
+	flushed *atomic.Int64
+	dropped *atomic.Int64
+	ready   func()
+	results *exportWindow
+}
+
+func (e *accountingLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	if err := e.Exporter.Export(ctx, records); err != nil {
+		e.dropped.Add(int64(len(records)))
+		if e.results != nil {
+			e.results.record(false)
+		}
+		return err
+	}
+	e.flushed.Add(int64(len(records)))
+	if e.results != nil {
+		e.results.record(true)
+	}
+	if e.ready != nil {
+		e.ready()
+	}
+	return nil
+}
+
+// retryLogExporter bounds the number of attempts of a single log export
+// to a hard count, unlike the SDK's elapsed-time based policy.
+type retryLogExporter struct {
+	sdklog.Exporter
+	retries int
+}
+
+func (e *retryLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	return withRetries(ctx, e.retries, func() error {
+		return e.Exporter.Export(ctx, records)
+	})
+}
+
+// retryMetricExporter bounds the number of attempts of a single metric
+// export to a hard count.
+type retryMetricExporter struct {
+	sdkmetric.Exporter
+	retries int
+}
+
+func (e *retryMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return withRetries(ctx, e.retries, func() error {
+		return e.Exporter.Export(ctx, rm)
+	})
+}
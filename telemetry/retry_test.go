@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// failingLogExporter fails every export and counts the attempts.
+type failingLogExporter struct {
+	calls int
+}
+
+func (e *failingLogExporter) Export(context.Context, []sdklog.Record) error {
+	e.calls++
+	return errors.New("unavailable")
+}
+
+func (e *failingLogExporter) Shutdown(context.Context) error   { return nil }
+func (e *failingLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestAccountingLogExporter(t *testing.T) {
+	ctx := context.Background()
+	var flushed, dropped atomic.Int64
+
+	// failed exports count every record as dropped.
+	failing := &accountingLogExporter{Exporter: &failingLogExporter{}, flushed: &flushed, dropped: &dropped}
+	if err := failing.Export(ctx, make([]sdklog.Record, 2)); err == nil {
+		t.Fatal("Export() = nil, want error")
+	}
+	if flushed.Load() != 0 || dropped.Load() != 2 {
+		t.Errorf("flushed/dropped = %d/%d, want 0/2", flushed.Load(), dropped.Load())
+	}
+
+	// successful exports count as flushed.
+	ok := &accountingLogExporter{Exporter: &countingLogExporter{}, flushed: &flushed, dropped: &dropped}
+	if err := ok.Export(ctx, make([]sdklog.Record, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if flushed.Load() != 3 || dropped.Load() != 2 {
+		t.Errorf("flushed/dropped = %d/%d, want 3/2", flushed.Load(), dropped.Load())
+	}
+}
+
+func TestMaxExportRetries(t *testing.T) {
+	mock := &failingLogExporter{}
+	exporter := &retryLogExporter{Exporter: mock, retries: 2}
+
+	err := exporter.Export(context.Background(), nil)
+	if err == nil {
+		t.Fatal("Export() = nil, want error after exhausting retries")
+	}
+	// one initial attempt plus two retries.
+	if mock.calls != 3 {
+		t.Errorf("export attempts = %d, want 3", mock.calls)
+	}
+}
+
+func TestWithRetriesStopsOnSuccess(t *testing.T) {
+	calls := 0
+	err := withRetries(context.Background(), 5, func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRetries() = %v, want nil", err)
+	}
+	if calls != 2 {
+		t.Errorf("attempts = %d, want 2", calls)
+	}
+}
@@ -0,0 +1,45 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// routingLogExporter sends error-and-above records to a dedicated
+// high-priority exporter and everything else to the embedded default
+// one, so an outage of the bulk collector cannot take error visibility
+// down with it.
+type routingLogExporter struct {
+	sdklog.Exporter
+	errors sdklog.Exporter
+}
+
+func (e *routingLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	var bulk, high []sdklog.Record
+	for _, record := range records {
+		if record.Severity() >= log.SeverityError {
+			high = append(high, record)
+		} else {
+			bulk = append(bulk, record)
+		}
+	}
+	var err error
+	if len(high) > 0 {
+		err = e.errors.Export(ctx, high)
+	}
+	if len(bulk) > 0 {
+		err = errors.Join(err, e.Exporter.Export(ctx, bulk))
+	}
+	return err
+}
+
+func (e *routingLogExporter) ForceFlush(ctx context.Context) error {
+	return errors.Join(e.errors.ForceFlush(ctx), e.Exporter.ForceFlush(ctx))
+}
+
+func (e *routingLogExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.errors.Shutdown(ctx), e.Exporter.Shutdown(ctx))
+}
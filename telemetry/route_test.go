@@ -0,0 +1,86 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// capturingLogExporter keeps every exported record.
+type capturingLogExporter struct {
+	records []sdklog.Record
+}
+
+func (e *capturingLogExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.records = append(e.records, records...)
+	return nil
+}
+
+func (e *capturingLogExporter) Shutdown(context.Context) error   { return nil }
+func (e *capturingLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestRoutingLogExporter(t *testing.T) {
+	bulk := &capturingLogExporter{}
+	high := &capturingLogExporter{}
+	exporter := &routingLogExporter{Exporter: bulk, errors: high}
+
+	record := func(sev log.Severity) sdklog.Record {
+		var r sdklog.Record
+		r.SetSeverity(sev)
+		return r
+	}
+	records := []sdklog.Record{
+		record(log.SeverityInfo),
+		record(log.SeverityError),
+		record(log.SeverityWarn),
+		record(log.SeverityFatal),
+	}
+	if err := exporter.Export(context.Background(), records); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(high.records) != 2 {
+		t.Fatalf("error-endpoint records = %d, want 2", len(high.records))
+	}
+	for _, r := range high.records {
+		if r.Severity() < log.SeverityError {
+			t.Errorf("severity %v routed to the error endpoint", r.Severity())
+		}
+	}
+	if len(bulk.records) != 2 {
+		t.Fatalf("default-endpoint records = %d, want 2", len(bulk.records))
+	}
+	for _, r := range bulk.records {
+		if r.Severity() >= log.SeverityError {
+			t.Errorf("severity %v routed to the default endpoint", r.Severity())
+		}
+	}
+}
+
+func TestErrorLogsEndpointConnection(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:          "127.0.0.1:4317",
+		Insecure:          true,
+		ServiceName:       "drone-runner-aws",
+		ErrorLogsEndpoint: "127.0.0.1:4327",
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}()
+
+	// the error destination gets its own collector connection.
+	if got := len(tel.conns); got != 2 {
+		t.Errorf("connection count = %d, want 2", got)
+	}
+}
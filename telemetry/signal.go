@@ -0,0 +1,49 @@
+package telemetry
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+)
+
+// signalShutdownTimeout bounds the flush triggered by a termination
+// signal, so a dead collector cannot stall process exit indefinitely.
+const signalShutdownTimeout = 10 * time.Second
+
+// watchSignals waits for SIGTERM or SIGINT and shuts the pipeline down
+// so buffered telemetry is flushed even when the caller never invokes
+// Shutdown. The handler is removed when the pipeline shuts down
+// through the regular path, so a restarted pipeline installs a fresh
+// one and a stopped one leaves signal delivery untouched.
+func (t *Telemetry) watchSignals(ctx context.Context) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-ctx.Done():
+		signal.Stop(ch)
+		t.wg.Done()
+	case sig := <-ch:
+		signal.Stop(ch)
+		// leave the wait group before Shutdown: it waits on the group
+		// this goroutine is part of.
+		t.wg.Done()
+		t.handleSignal(sig)
+	}
+}
+
+// handleSignal performs the signal-triggered shutdown. Shutdown itself
+// is a no-op on a stopped pipeline, so a second invocation — the other
+// signal arriving during the flush, say — does nothing.
+func (t *Telemetry) handleSignal(sig os.Signal) {
+	logrus.WithField("signal", sig.String()).Info("telemetry: shutting down on signal")
+	ctx, cancel := context.WithTimeout(context.Background(), signalShutdownTimeout)
+	defer cancel()
+	if err := t.Shutdown(ctx); err != nil {
+		otel.Handle(err)
+	}
+}
@@ -0,0 +1,47 @@
+package telemetry
+
+import (
+	"context"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleSignalShutsDownOnce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:             "127.0.0.1:4317",
+		Insecure:             true,
+		ServiceName:          "drone-runner-aws",
+		InstallSignalHandler: true,
+		CollectOnShutdown:    boolPtr(false),
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var shutdowns atomic.Int64
+	tel.RegisterShutdownFunc(func(context.Context) error {
+		shutdowns.Add(1)
+		return nil
+	})
+
+	tel.handleSignal(syscall.SIGTERM)
+	if got := shutdowns.Load(); got != 1 {
+		t.Fatalf("shutdown funcs run = %d, want 1", got)
+	}
+	tel.mu.RLock()
+	started := tel.started
+	tel.mu.RUnlock()
+	if started {
+		t.Error("pipeline still started after the signal")
+	}
+
+	// the second signal of a SIGTERM-then-SIGINT sequence is a no-op.
+	tel.handleSignal(syscall.SIGINT)
+	if got := shutdowns.Load(); got != 1 {
+		t.Errorf("shutdown funcs run after second signal = %d, want 1", got)
+	}
+}
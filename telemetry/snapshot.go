@@ -0,0 +1,115 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// metricSnapshot is the JSON document printed by DumpMetricsOnShutdown.
+type metricSnapshot struct {
+	Time    time.Time        `json:"time"`
+	Metrics []snapshotMetric `json:"metrics"`
+}
+
+type snapshotMetric struct {
+	Name   string          `json:"name"`
+	Scope  string          `json:"scope,omitempty"`
+	Points []snapshotPoint `json:"points"`
+}
+
+type snapshotPoint struct {
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Value      interface{}            `json:"value,omitempty"`
+	Count      uint64                 `json:"count,omitempty"`
+	Sum        float64                `json:"sum,omitempty"`
+}
+
+// dumpMetricsSnapshot collects the final metric values from the
+// snapshot reader and prints them as one JSON document, so CI runs
+// show the closing state without a collector. Failures are reported
+// through the otel error handler; the shutdown proceeds regardless.
+// The caller must hold t.mu.
+func (t *Telemetry) dumpMetricsSnapshot(ctx context.Context) {
+	if t.snapshotReader == nil {
+		return
+	}
+	var rm metricdata.ResourceMetrics
+	if err := t.snapshotReader.Collect(ctx, &rm); err != nil {
+		otel.Handle(err)
+		return
+	}
+	var writer io.Writer = os.Stdout
+	if t.snapshotWriter != nil {
+		writer = t.snapshotWriter
+	}
+	if err := json.NewEncoder(writer).Encode(buildSnapshot(time.Now(), &rm)); err != nil {
+		otel.Handle(err)
+	}
+}
+
+// buildSnapshot flattens collected metrics into the snapshot document.
+func buildSnapshot(now time.Time, rm *metricdata.ResourceMetrics) metricSnapshot {
+	snap := metricSnapshot{Time: now}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			snap.Metrics = append(snap.Metrics, snapshotMetric{
+				Name:   m.Name,
+				Scope:  sm.Scope.Name,
+				Points: snapshotPoints(m.Data),
+			})
+		}
+	}
+	return snap
+}
+
+// snapshotPoints extracts the data points of one aggregation. Scalar
+// aggregations carry their value; histograms carry count and sum.
+func snapshotPoints(data metricdata.Aggregation) []snapshotPoint {
+	var points []snapshotPoint
+	switch agg := data.(type) {
+	case metricdata.Sum[int64]:
+		for _, dp := range agg.DataPoints {
+			points = append(points, snapshotPoint{Attributes: snapshotAttributes(dp.Attributes), Value: dp.Value})
+		}
+	case metricdata.Sum[float64]:
+		for _, dp := range agg.DataPoints {
+			points = append(points, snapshotPoint{Attributes: snapshotAttributes(dp.Attributes), Value: dp.Value})
+		}
+	case metricdata.Gauge[int64]:
+		for _, dp := range agg.DataPoints {
+			points = append(points, snapshotPoint{Attributes: snapshotAttributes(dp.Attributes), Value: dp.Value})
+		}
+	case metricdata.Gauge[float64]:
+		for _, dp := range agg.DataPoints {
+			points = append(points, snapshotPoint{Attributes: snapshotAttributes(dp.Attributes), Value: dp.Value})
+		}
+	case metricdata.Histogram[int64]:
+		for _, dp := range agg.DataPoints {
+			points = append(points, snapshotPoint{Attributes: snapshotAttributes(dp.Attributes), Count: dp.Count, Sum: float64(dp.Sum)})
+		}
+	case metricdata.Histogram[float64]:
+		for _, dp := range agg.DataPoints {
+			points = append(points, snapshotPoint{Attributes: snapshotAttributes(dp.Attributes), Count: dp.Count, Sum: dp.Sum})
+		}
+	}
+	return points
+}
+
+// snapshotAttributes flattens an attribute set for JSON encoding.
+func snapshotAttributes(set attribute.Set) map[string]interface{} {
+	if set.Len() == 0 {
+		return nil
+	}
+	attrs := make(map[string]interface{}, set.Len())
+	for _, kv := range set.ToSlice() {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return attrs
+}
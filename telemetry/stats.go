@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"sync"
+	"time"
+)
+
+// exportWindowSize is the sliding window the export success ratio is
+// computed over.
+const exportWindowSize = 5 * time.Minute
+
+// exportResult is one export outcome with the time it was observed.
+type exportResult struct {
+	at time.Time
+	ok bool
+}
+
+// exportWindow tracks recent export outcomes and computes the success
+// ratio over a sliding time window, shared by the log and metric
+// export paths.
+type exportWindow struct {
+	mu      sync.Mutex
+	results []exportResult
+}
+
+// record notes one export outcome.
+func (w *exportWindow) record(ok bool) {
+	w.recordAt(time.Now(), ok)
+}
+
+func (w *exportWindow) recordAt(at time.Time, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.results = append(w.results, exportResult{at: at, ok: ok})
+	w.trim(at)
+}
+
+// ratio returns the fraction of successful exports within the window.
+// The second return is false when no export fell inside it, so an idle
+// pipeline is distinguishable from a failing one.
+func (w *exportWindow) ratio() (float64, bool) {
+	return w.ratioAt(time.Now())
+}
+
+func (w *exportWindow) ratioAt(now time.Time) (float64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.trim(now)
+	if len(w.results) == 0 {
+		return 0, false
+	}
+	succeeded := 0
+	for _, r := range w.results {
+		if r.ok {
+			succeeded++
+		}
+	}
+	return float64(succeeded) / float64(len(w.results)), true
+}
+
+// trim drops results that have aged out of the window. The caller must
+// hold w.mu.
+func (w *exportWindow) trim(now time.Time) {
+	cutoff := now.Add(-exportWindowSize)
+	kept := 0
+	for kept < len(w.results) && w.results[kept].at.Before(cutoff) {
+		kept++
+	}
+	w.results = w.results[kept:]
+}
@@ -0,0 +1,85 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestExportWindowRatio(t *testing.T) {
+	now := time.Now()
+	w := &exportWindow{}
+
+	// an empty window reports no ratio at all.
+	if _, ok := w.ratioAt(now); ok {
+		t.Error("empty window reported a ratio")
+	}
+
+	w.recordAt(now, true)
+	w.recordAt(now, true)
+	w.recordAt(now, true)
+	w.recordAt(now, false)
+	if ratio, ok := w.ratioAt(now); !ok || ratio != 0.75 {
+		t.Errorf("ratio = %v/%v, want 0.75/true", ratio, ok)
+	}
+
+	// results age out of the window; only the recent failure remains.
+	later := now.Add(exportWindowSize + time.Second)
+	w.recordAt(later, false)
+	if ratio, ok := w.ratioAt(later); !ok || ratio != 0 {
+		t.Errorf("ratio after aging = %v/%v, want 0/true", ratio, ok)
+	}
+
+	// once everything has aged out the window is empty again.
+	if _, ok := w.ratioAt(later.Add(exportWindowSize + time.Second)); ok {
+		t.Error("fully aged window reported a ratio")
+	}
+}
+
+func TestExportSuccessRatioGauge(t *testing.T) {
+	ctx := context.Background()
+	tel := New(Config{})
+
+	reader := sdkmetric.NewManualReader()
+	tel.meterProvider = sdkmetric.NewMeterProvider(tel.meterProviderOptions(reader)...)
+	defer tel.meterProvider.Shutdown(ctx) //nolint:errcheck
+	if err := tel.registerExportRatio(); err != nil {
+		t.Fatal(err)
+	}
+
+	collectRatio := func() (float64, bool) {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(ctx, &rm); err != nil {
+			t.Fatal(err)
+		}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "otel_export_success_ratio" {
+					continue
+				}
+				data, ok := m.Data.(metricdata.Gauge[float64])
+				if !ok || len(data.DataPoints) != 1 {
+					t.Fatalf("unexpected gauge shape %T %+v", m.Data, m.Data)
+				}
+				return data.DataPoints[0].Value, true
+			}
+		}
+		return 0, false
+	}
+
+	// before any export the gauge observes nothing.
+	if ratio, ok := collectRatio(); ok {
+		t.Errorf("ratio observed with no exports = %v", ratio)
+	}
+
+	for _, ok := range []bool{true, true, false, true} {
+		tel.exportResults.record(ok)
+	}
+	if ratio, ok := collectRatio(); !ok || ratio != 0.75 {
+		t.Errorf("ratio = %v/%v, want 0.75/true", ratio, ok)
+	}
+}
@@ -0,0 +1,1488 @@
+// Package telemetry exports runner logs and metrics to an OpenTelemetry
+// collector. Logs are forwarded from logrus through a hook, metrics are
+// exported by a periodic reader.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	// registers the gzip compressor the exporters name through
+	// WithCompressor.
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+const (
+	instrumentationName = "github.com/drone-runners/drone-runner-aws/telemetry"
+
+	// metricInterval is the steady-state export interval of the
+	// periodic metrics reader.
+	metricInterval = 30 * time.Second
+
+	// metricTimeout bounds each export of the periodic metrics reader.
+	// It is set explicitly rather than relying on the SDK default, so
+	// ReaderConfig reports the value that is actually in effect.
+	metricTimeout = 30 * time.Second
+)
+
+// ReaderConfig is the effective configuration of the periodic metrics
+// reader, captured at construction because the reader itself does not
+// expose it. Both fields are zero when metrics are exported manually.
+type ReaderConfig struct {
+	// Interval is the steady-state export interval.
+	Interval time.Duration
+	// Timeout bounds each export attempt.
+	Timeout time.Duration
+}
+
+// Telemetry owns the exporters and providers for a single collector
+// endpoint. It is created stopped; Start dials the collector and
+// Shutdown flushes and releases it.
+type Telemetry struct {
+	mu           sync.RWMutex
+	config       Config
+	headers      map[string]string
+	headerSource *headerSource
+	res          *resource.Resource
+	logContext   map[string]string
+
+	loggerProvider  *sdklog.LoggerProvider
+	meterProvider   *sdkmetric.MeterProvider
+	tracerProvider  *sdktrace.TracerProvider
+	logger          log.Logger
+	errorsTotal     metric.Int64Counter
+	recordAttrsHist metric.Int64Histogram
+	recordBodyHist  metric.Int64Histogram
+	queueWaitHist   metric.Float64Histogram
+	hookPanics      metric.Int64Counter
+	panicLogOnce    sync.Once
+	manualReader    *sdkmetric.ManualReader
+	manualExporter  sdkmetric.Exporter
+	snapshotReader  *sdkmetric.ManualReader
+	componentCount  int
+	readerConfig    ReaderConfig
+
+	conns          []*grpc.ClientConn
+	connCancel     context.CancelFunc
+	ramp           *rampGate
+	backoff        *backoffState
+	dropExports    atomic.Bool
+	pendingReload  atomic.Bool
+	metricsPaused  atomic.Bool
+	logsPaused     atomic.Bool
+	degraded       atomic.Bool
+	activeJobs     atomic.Int64
+	recordsFlushed atomic.Int64
+	recordsDropped atomic.Int64
+	emitSlots      chan struct{}
+
+	ready         chan struct{}
+	readyOnce     sync.Once
+	exportResults *exportWindow
+
+	hostname       string
+	debugWriter    io.Writer
+	snapshotWriter io.Writer
+	hook           *Hook
+	installed      *installedHook
+	persistQueue   *persistentLogExporter
+	bgCtx          context.Context
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup
+	started        bool
+	lazyPending    bool
+	lastRebuild    time.Time
+	shutdownFuncs  []ShutdownFunc
+}
+
+// ShutdownFunc is a cleanup function run during Shutdown.
+type ShutdownFunc func(context.Context) error
+
+// New returns a stopped Telemetry for the given configuration.
+func New(config Config) *Telemetry {
+	t := &Telemetry{config: config, headerSource: &headerSource{}}
+	t.ready = make(chan struct{})
+	t.exportResults = &exportWindow{}
+	t.hostname = hostShortname()
+	t.hook = &Hook{telemetry: t}
+	if config.LogLevel != "" {
+		if level, err := logrus.ParseLevel(config.LogLevel); err == nil {
+			t.hook.minLevel = &level
+		}
+	}
+	return t
+}
+
+// Hook returns the logrus hook that forwards entries to the collector.
+// The hook is safe to register before Start and drops entries while the
+// pipeline is stopped.
+func (t *Telemetry) Hook() *Hook {
+	return t.hook
+}
+
+// InstallHook registers the telemetry hook on the given logger. When
+// setup runs again — for example on a config reload — any registration
+// this Telemetry previously installed is switched off first, so
+// repeated installs never cause duplicate exports. logrus cannot remove
+// hooks, so the stale one stays on the logger but drops every entry.
+func (t *Telemetry) InstallHook(logger *logrus.Logger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.installed != nil {
+		t.installed.disabled.Store(true)
+	}
+	t.installed = &installedHook{hook: t.hook}
+	logger.AddHook(t.installed)
+}
+
+// Start builds the exporters and providers and, when a headers file and
+// refresh interval are configured, launches the background refresher.
+// On success it emits a single structured line summarizing the
+// effective configuration, with header values masked.
+func (t *Telemetry) Start(ctx context.Context) error {
+	if skipped, minInterval := t.startDebounced(); skipped {
+		t.pendingReload.Store(true)
+		logrus.WithField("min_reconfigure_interval", minInterval.String()).
+			Warn("telemetry: start skipped; previous pipeline rebuild too recent")
+		return nil
+	}
+	started, err := t.start(ctx)
+	if err != nil || !started {
+		return err
+	}
+	logrus.WithFields(t.startupFields()).Info("telemetry: started")
+	return nil
+}
+
+// startDebounced reports whether this Start call falls within
+// MinReconfigureInterval of the previous pipeline rebuild while the
+// pipeline is stopped, meaning the call must be skipped. A running
+// pipeline is never debounced: Start is a no-op there anyway.
+func (t *Telemetry) startDebounced() (bool, time.Duration) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.started {
+		return false, 0
+	}
+	return t.rebuildDebounced(), t.config.MinReconfigureInterval
+}
+
+// rebuildDebounced reports whether a pipeline rebuild arriving now is
+// within MinReconfigureInterval of the previous one. The caller must
+// hold t.mu.
+func (t *Telemetry) rebuildDebounced() bool {
+	minInterval := t.config.MinReconfigureInterval
+	if minInterval <= 0 || t.lastRebuild.IsZero() {
+		return false
+	}
+	return time.Since(t.lastRebuild) < minInterval
+}
+
+// start performs the locked portion of Start and reports whether the
+// pipeline transitioned from stopped to started.
+func (t *Telemetry) start(ctx context.Context) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started || !t.config.enabled() {
+		return false, nil
+	}
+	if t.config.RequireServiceName && t.config.ServiceName == "" {
+		return false, errors.New("telemetry: ServiceName is required")
+	}
+	if err := t.config.Validate(); err != nil {
+		return false, err
+	}
+
+	headers, err := t.loadHeaders()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := NewResource(ctx, t.config)
+	if err != nil {
+		return false, fmt.Errorf("telemetry: build resource: %w", err)
+	}
+	// stamp when this pipeline came up and the hash of the config it
+	// runs with, so telemetry gaps correlate with restarts and config
+	// drift shows up across a fleet.
+	res, err = resource.Merge(res, resource.NewSchemaless(
+		attribute.String("otel.pipeline.start_time", time.Now().UTC().Format(time.RFC3339Nano)),
+		attribute.String("runner.config_hash", t.config.configHash())))
+	if err != nil {
+		return false, fmt.Errorf("telemetry: build resource: %w", err)
+	}
+	t.res = capResourceAttributes(res, t.config.MaxResourceAttributes)
+
+	t.recordsFlushed.Store(0)
+	t.recordsDropped.Store(0)
+	// a previous Shutdown without collect-on-shutdown leaves the gate
+	// closed; a restart opens it again.
+	t.dropExports.Store(false)
+	t.bgCtx, t.cancel = context.WithCancel(context.Background())
+
+	if t.config.LazyInit {
+		// the exporters are built on the first emit instead; keep the
+		// loaded headers for that deferred build.
+		t.headers = headers
+		t.lazyPending = true
+		t.started = true
+		return true, nil
+	}
+
+	if err := t.buildProviders(ctx, headers); err != nil {
+		t.cancel()
+		return false, err
+	}
+	if t.config.ValidateEndpoint {
+		if err := t.validateEndpoint(ctx); err != nil {
+			if e := t.shutdownProviders(ctx); e != nil {
+				otel.Handle(e)
+			}
+			t.loggerProvider = nil
+			t.meterProvider = nil
+			t.logger = nil
+			t.cancel()
+			t.cancel = nil
+			return false, err
+		}
+	}
+	t.started = true
+	t.launchBackground()
+	return true, nil
+}
+
+// launchBackground starts the background workers configured alongside
+// the pipeline. The caller must hold t.mu and have a current provider.
+func (t *Telemetry) launchBackground() {
+	if t.config.HeadersFile != "" && t.config.HeaderRefreshInterval > 0 {
+		t.wg.Add(1)
+		go t.watchHeaders(t.bgCtx, t.config.HeaderRefreshInterval)
+	}
+	if t.config.MetricsInitialDelay > 0 {
+		t.wg.Add(1)
+		go t.flushMetricsAfter(t.bgCtx, t.config.MetricsInitialDelay)
+	}
+	if t.config.EnabledFunc != nil && t.config.EnabledPollInterval > 0 {
+		t.wg.Add(1)
+		go t.watchEnabled(t.bgCtx, t.config.EnabledFunc, t.config.EnabledPollInterval)
+	}
+	if len(t.config.LazyResourceDetectors) > 0 {
+		t.wg.Add(1)
+		go t.detectResources(t.bgCtx, t.config.LazyResourceDetectors)
+	}
+	if t.config.InstallSignalHandler {
+		t.wg.Add(1)
+		go t.watchSignals(t.bgCtx)
+	}
+	if queue := t.persistQueue; queue != nil {
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			// re-export what a previous process left behind.
+			if err := queue.replay(t.bgCtx); err != nil {
+				otel.Handle(err)
+			}
+		}()
+	}
+}
+
+// initLazy builds the providers deferred by LazyInit. It is invoked on
+// the first emit and returns the logger to emit to, or nil when the
+// deferred build fails or the pipeline is stopped.
+func (t *Telemetry) initLazy() log.Logger {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.lazyPending || !t.started {
+		return t.logger
+	}
+	t.lazyPending = false
+	if err := t.buildProviders(t.bgCtx, t.headers); err != nil {
+		otel.Handle(err)
+		return nil
+	}
+	t.launchBackground()
+	return t.logger
+}
+
+// Reconfigure applies a new configuration to the pipeline, rebuilding
+// the exporters in place. When the metrics destination changes, the
+// current meter provider is flushed first so the metric window
+// accumulated so far is exported to the old endpoint instead of being
+// lost at the boundary; the flush honors ctx's deadline. On a stopped
+// pipeline only the stored configuration is replaced. A call within
+// MinReconfigureInterval of the previous rebuild is skipped with a
+// warning.
+//
+// Reconfigure rebuilds the exporters but does not relaunch the
+// background workers started alongside the pipeline — the headers
+// refresher, the enabled-flag poller, and the signal handler keep the
+// settings they were launched with. A change to one of those fields on
+// a running pipeline is logged as a warning and takes effect on the
+// next Shutdown/Start cycle.
+func (t *Telemetry) Reconfigure(ctx context.Context, config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if skipped, minInterval := t.reconfigureDebounced(); skipped {
+		t.pendingReload.Store(true)
+		logrus.WithField("min_reconfigure_interval", minInterval.String()).
+			Warn("telemetry: reconfigure skipped; previous pipeline rebuild too recent")
+		return nil
+	}
+	t.mu.Lock()
+	if !t.started || t.lazyPending {
+		t.config = config
+		t.mu.Unlock()
+		return nil
+	}
+	// logged after the lock is released: the hook's Fire takes t.mu.
+	unlaunched := watcherFieldsChanged(t.config, config)
+	if t.meterProvider != nil && metricsDestination(t.config) != metricsDestination(config) {
+		if err := t.meterProvider.ForceFlush(ctx); err != nil {
+			otel.Handle(err)
+		}
+	}
+	t.config = config
+	headers, err := t.loadHeaders()
+	if err == nil {
+		err = t.buildProviders(ctx, headers)
+	}
+	t.mu.Unlock()
+	for _, field := range unlaunched {
+		logrus.WithField("field", field).
+			Warn("telemetry: reconfigure does not relaunch background workers; the change takes effect on the next Start")
+	}
+	return err
+}
+
+// watcherFieldsChanged lists the watcher-backed config fields that
+// differ between the running and the incoming configuration, so
+// Reconfigure can surface that those changes are not picked up by the
+// already-running workers. Function values compare by presence only.
+func watcherFieldsChanged(current, next Config) []string {
+	var fields []string
+	if current.HeadersFile != next.HeadersFile || current.HeaderRefreshInterval != next.HeaderRefreshInterval {
+		fields = append(fields, "HeadersFile/HeaderRefreshInterval")
+	}
+	if (current.EnabledFunc == nil) != (next.EnabledFunc == nil) || current.EnabledPollInterval != next.EnabledPollInterval {
+		fields = append(fields, "EnabledFunc/EnabledPollInterval")
+	}
+	if current.MetricsInitialDelay != next.MetricsInitialDelay {
+		fields = append(fields, "MetricsInitialDelay")
+	}
+	if current.InstallSignalHandler != next.InstallSignalHandler {
+		fields = append(fields, "InstallSignalHandler")
+	}
+	return fields
+}
+
+// reconfigureDebounced is the Reconfigure counterpart of
+// startDebounced. A stopped or lazy-pending pipeline is never
+// debounced: Reconfigure only replaces the stored configuration there.
+func (t *Telemetry) reconfigureDebounced() (bool, time.Duration) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.started || t.lazyPending {
+		return false, 0
+	}
+	return t.rebuildDebounced(), t.config.MinReconfigureInterval
+}
+
+// metricsDestination resolves where a configuration sends metrics.
+func metricsDestination(c Config) string {
+	if c.MetricsFormat == MetricsFormatPrometheusRemoteWrite {
+		return c.RemoteWriteURL
+	}
+	return c.Endpoint
+}
+
+// flushMetricsAfter forces a one-shot metrics collection once the given
+// delay elapses, ahead of the periodic reader's first interval.
+func (t *Telemetry) flushMetricsAfter(ctx context.Context, delay time.Duration) {
+	defer t.wg.Done()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+	t.mu.RLock()
+	provider := t.meterProvider
+	t.mu.RUnlock()
+	if provider == nil {
+		return
+	}
+	if err := provider.ForceFlush(ctx); err != nil {
+		otel.Handle(err)
+	}
+}
+
+// RegisterShutdownFunc hooks fn into the Shutdown lifecycle, so
+// integrators can flush their own sinks alongside the built-in
+// providers. Errors are aggregated with the provider shutdown errors.
+func (t *Telemetry) RegisterShutdownFunc(fn ShutdownFunc) {
+	t.mu.Lock()
+	t.shutdownFuncs = append(t.shutdownFuncs, fn)
+	t.mu.Unlock()
+}
+
+// Shutdown stops the background refresher, flushes and shuts down the
+// providers, and runs any registered shutdown funcs.
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.started {
+		return nil
+	}
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+	t.mu.Lock()
+
+	if !t.config.collectOnShutdown() {
+		t.dropExports.Store(true)
+	}
+	if t.config.DumpMetricsOnShutdown {
+		t.dumpMetricsSnapshot(ctx)
+	}
+	err := t.shutdownProviders(ctx)
+	for _, fn := range t.shutdownFuncs {
+		err = errors.Join(err, fn(ctx))
+	}
+	t.shutdownFuncs = nil
+	t.loggerProvider = nil
+	t.meterProvider = nil
+	t.logger = nil
+	t.trackComponents()
+	t.started = false
+	t.lazyPending = false
+	return err
+}
+
+// ShutdownReport accounts for the log records handled by the pipeline
+// by the time Shutdown completed.
+type ShutdownReport struct {
+	// RecordsFlushed is the number of records successfully exported.
+	RecordsFlushed int64
+	// RecordsDropped is the number of records whose export failed.
+	RecordsDropped int64
+}
+
+// ShutdownWithReport is Shutdown with an accounting of flushed versus
+// dropped records, for tests and strict operators that must verify
+// nothing was lost.
+func (t *Telemetry) ShutdownWithReport(ctx context.Context) (ShutdownReport, error) {
+	err := t.Shutdown(ctx)
+	return ShutdownReport{
+		RecordsFlushed: t.recordsFlushed.Load(),
+		RecordsDropped: t.recordsDropped.Load(),
+	}, err
+}
+
+// buildProviders replaces the current exporters and providers with
+// fresh ones using the given headers. The caller must hold t.mu.
+func (t *Telemetry) buildProviders(ctx context.Context, headers map[string]string) error {
+	if t.config.Debug {
+		return t.buildDebugProviders(ctx, headers)
+	}
+	// connections are pooled per endpoint and TLS mode, so signals
+	// targeting the same collector share one conn instead of each
+	// dialing their own.
+	var conns []*grpc.ClientConn
+	dialed := map[string]*grpc.ClientConn{}
+	dial := func(endpoint string, insecureConn bool) (*grpc.ClientConn, error) {
+		key := fmt.Sprintf("%s|%t", endpoint, insecureConn)
+		if conn, ok := dialed[key]; ok {
+			return conn, nil
+		}
+		conn, err := t.dialCollector(endpoint, insecureConn)
+		if err != nil {
+			return nil, err
+		}
+		dialed[key] = conn
+		conns = append(conns, conn)
+		return conn, nil
+	}
+	closeConns := func() {
+		for _, conn := range conns {
+			conn.Close() //nolint:errcheck
+		}
+	}
+	logConn, err := dial(t.config.logsEndpoint(), t.config.logsInsecure())
+	if err != nil {
+		return fmt.Errorf("telemetry: dial collector: %w", err)
+	}
+
+	retryPolicy := t.config.exporterRetry()
+	logOpts := []otlploggrpc.Option{
+		otlploggrpc.WithGRPCConn(logConn),
+	}
+	if retryPolicy.apply {
+		logOpts = append(logOpts, otlploggrpc.WithRetry(otlploggrpc.RetryConfig{
+			Enabled:         retryPolicy.enabled,
+			InitialInterval: retryPolicy.initial,
+			MaxInterval:     retryPolicy.max,
+			MaxElapsedTime:  retryPolicy.elapsed,
+		}))
+	}
+	if t.config.Compression == CompressionGzip {
+		logOpts = append(logOpts, otlploggrpc.WithCompressor(CompressionGzip))
+	}
+	logExporter, err := otlploggrpc.New(ctx, logOpts...)
+	if err != nil {
+		closeConns()
+		return fmt.Errorf("telemetry: create log exporter: %w", err)
+	}
+
+	var metricExporter sdkmetric.Exporter
+	if t.config.MetricsFormat == MetricsFormatPrometheusRemoteWrite {
+		metricExporter = newRemoteWriteExporter(t.config.RemoteWriteURL)
+	} else {
+		metricConn, err := dial(t.config.metricsEndpoint(), t.config.metricsInsecure())
+		if err != nil {
+			closeConns()
+			return fmt.Errorf("telemetry: dial collector: %w", err)
+		}
+		metricOpts := []otlpmetricgrpc.Option{
+			otlpmetricgrpc.WithGRPCConn(metricConn),
+		}
+		if retryPolicy.apply {
+			metricOpts = append(metricOpts, otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{
+				Enabled:         retryPolicy.enabled,
+				InitialInterval: retryPolicy.initial,
+				MaxInterval:     retryPolicy.max,
+				MaxElapsedTime:  retryPolicy.elapsed,
+			}))
+		}
+		if t.config.MetricTemporality == TemporalityDelta {
+			metricOpts = append(metricOpts, otlpmetricgrpc.WithTemporalitySelector(deltaTemporality))
+		}
+		if t.config.Compression == CompressionGzip {
+			metricOpts = append(metricOpts, otlpmetricgrpc.WithCompressor(CompressionGzip))
+		}
+		metricExporter, err = otlpmetricgrpc.New(ctx, metricOpts...)
+		if err != nil {
+			closeConns()
+			return fmt.Errorf("telemetry: create metric exporter: %w", err)
+		}
+	}
+
+	var traceExporter *otlptrace.Exporter
+	if t.config.ExportTraces {
+		traceConn, err := dial(t.config.tracesEndpoint(), t.config.tracesInsecure())
+		if err != nil {
+			closeConns()
+			return fmt.Errorf("telemetry: dial collector: %w", err)
+		}
+		traceOpts := []otlptracegrpc.Option{
+			otlptracegrpc.WithGRPCConn(traceConn),
+		}
+		if retryPolicy.apply {
+			traceOpts = append(traceOpts, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+				Enabled:         retryPolicy.enabled,
+				InitialInterval: retryPolicy.initial,
+				MaxInterval:     retryPolicy.max,
+				MaxElapsedTime:  retryPolicy.elapsed,
+			}))
+		}
+		if t.config.Compression == CompressionGzip {
+			traceOpts = append(traceOpts, otlptracegrpc.WithCompressor(CompressionGzip))
+		}
+		traceExporter, err = otlptracegrpc.New(ctx, traceOpts...)
+		if err != nil {
+			closeConns()
+			return fmt.Errorf("telemetry: create trace exporter: %w", err)
+		}
+	}
+
+	var errExporter *otlploggrpc.Exporter
+	if t.config.ErrorLogsEndpoint != "" {
+		errConn, err := dial(t.config.ErrorLogsEndpoint, t.config.logsInsecure())
+		if err != nil {
+			closeConns()
+			return fmt.Errorf("telemetry: dial error-logs collector: %w", err)
+		}
+		errOpts := []otlploggrpc.Option{
+			otlploggrpc.WithGRPCConn(errConn),
+		}
+		if retryPolicy.apply {
+			errOpts = append(errOpts, otlploggrpc.WithRetry(otlploggrpc.RetryConfig{
+				Enabled:         retryPolicy.enabled,
+				InitialInterval: retryPolicy.initial,
+				MaxInterval:     retryPolicy.max,
+				MaxElapsedTime:  retryPolicy.elapsed,
+			}))
+		}
+		if t.config.Compression == CompressionGzip {
+			errOpts = append(errOpts, otlploggrpc.WithCompressor(CompressionGzip))
+		}
+		errExporter, err = otlploggrpc.New(ctx, errOpts...)
+		if err != nil {
+			closeConns()
+			return fmt.Errorf("telemetry: create error-logs exporter: %w", err)
+		}
+	}
+
+	if err := t.shutdownProviders(ctx); err != nil {
+		otel.Handle(err)
+	}
+
+	var logExp sdklog.Exporter = logExporter
+	metricExp := metricExporter
+	logExp = &annotatedLogExporter{Exporter: logExp, endpoint: t.config.logsEndpoint()}
+	if errExporter != nil {
+		logExp = &routingLogExporter{
+			Exporter: logExp,
+			errors:   &annotatedLogExporter{Exporter: errExporter, endpoint: t.config.ErrorLogsEndpoint},
+		}
+	}
+	metricEndpoint := t.config.metricsEndpoint()
+	if t.config.MetricsFormat == MetricsFormatPrometheusRemoteWrite {
+		metricEndpoint = t.config.RemoteWriteURL
+	}
+	if len(t.config.InstrumentAggregation) > 0 {
+		metricExp = &reaggregateMetricExporter{Exporter: metricExp, overrides: t.config.InstrumentAggregation}
+	}
+	if t.config.DropEmptyMetrics {
+		metricExp = &emptyFilterMetricExporter{Exporter: metricExp}
+	}
+	metricExp = &annotatedMetricExporter{Exporter: metricExp, endpoint: metricEndpoint}
+	if t.config.MaxExportRetries > 0 {
+		logExp = &retryLogExporter{Exporter: logExp, retries: t.config.MaxExportRetries}
+		metricExp = &retryMetricExporter{Exporter: metricExp, retries: t.config.MaxExportRetries}
+	}
+	if t.config.FallbackLogFile != "" {
+		logExp = &fallbackLogExporter{Exporter: logExp, conn: logConn, path: t.config.FallbackLogFile}
+	}
+	if t.config.PostReconnectRampDelay > 0 {
+		if t.ramp == nil {
+			t.ramp = &rampGate{delay: t.config.PostReconnectRampDelay}
+		}
+		logExp = &rampLogExporter{Exporter: logExp, gate: t.ramp}
+		metricExp = &rampMetricExporter{Exporter: metricExp, gate: t.ramp}
+	}
+	if t.config.AdaptiveExportBackoff && !t.config.ManualMetricsExport {
+		if t.backoff == nil {
+			t.backoff = &backoffState{base: t.config.metricsInterval()}
+		}
+		metricExp = &backoffMetricExporter{Exporter: metricExp, state: t.backoff}
+	}
+	if t.config.PersistentQueueDir != "" {
+		if err := os.MkdirAll(t.config.PersistentQueueDir, 0700); err != nil {
+			closeConns()
+			return fmt.Errorf("telemetry: create queue directory: %w", err)
+		}
+		persist := &persistentLogExporter{Exporter: logExp, dir: t.config.PersistentQueueDir}
+		t.persistQueue = persist
+		logExp = persist
+	}
+	metricExp = &gatedMetricExporter{Exporter: metricExp, dropping: &t.dropExports, paused: &t.metricsPaused, ready: t.markReady, results: t.exportResults}
+	logExp = &accountingLogExporter{Exporter: logExp, flushed: &t.recordsFlushed, dropped: &t.recordsDropped, ready: t.markReady, results: t.exportResults}
+
+	t.emitSlots = nil
+	if t.config.MaxConcurrentEmits > 0 {
+		t.emitSlots = make(chan struct{}, t.config.MaxConcurrentEmits)
+	}
+
+	t.conns = conns
+	t.loggerProvider = sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewSimpleProcessor(logExp)),
+		sdklog.WithResource(t.res),
+	)
+	var metricReader sdkmetric.Reader
+	if t.config.ManualMetricsExport {
+		var manualOpts []sdkmetric.ManualReaderOption
+		if t.config.MetricTemporality == TemporalityDelta {
+			manualOpts = append(manualOpts, sdkmetric.WithTemporalitySelector(deltaTemporality))
+		}
+		if t.config.PrometheusGatherer != nil {
+			manualOpts = append(manualOpts, sdkmetric.WithProducer(NewPrometheusProducer(t.config.PrometheusGatherer)))
+		}
+		reader := sdkmetric.NewManualReader(manualOpts...)
+		t.manualReader = reader
+		t.manualExporter = metricExp
+		t.readerConfig = ReaderConfig{}
+		metricReader = reader
+	} else {
+		interval := t.config.metricsInterval()
+		periodicOpts := []sdkmetric.PeriodicReaderOption{
+			sdkmetric.WithInterval(interval),
+			sdkmetric.WithTimeout(metricTimeout),
+		}
+		if t.config.PrometheusGatherer != nil {
+			periodicOpts = append(periodicOpts, sdkmetric.WithProducer(NewPrometheusProducer(t.config.PrometheusGatherer)))
+		}
+		metricReader = sdkmetric.NewPeriodicReader(metricExp, periodicOpts...)
+		t.readerConfig = ReaderConfig{Interval: interval, Timeout: metricTimeout}
+	}
+	t.meterProvider = sdkmetric.NewMeterProvider(t.meterProviderOptions(metricReader)...)
+	if traceExporter != nil {
+		t.tracerProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(traceExporter),
+			sdktrace.WithResource(t.res),
+		)
+		// register globally so spans started through otel.Tracer — the
+		// hook's log spans included — are exported too.
+		otel.SetTracerProvider(t.tracerProvider)
+	}
+	t.logger = t.loggerProvider.Logger(instrumentationName)
+	t.headers = headers
+	t.headerSource.set(headers)
+	t.registerInstruments()
+	t.watchConnectivity(conns)
+	t.lastRebuild = time.Now()
+	t.pendingReload.Store(false)
+	t.trackComponents()
+	return nil
+}
+
+// buildDebugProviders is the Debug-mode variant of buildProviders. It
+// wires stdout exporters that pretty-print every record and metric
+// instead of dialing a collector, so local runs show their telemetry
+// inline. The caller must hold t.mu.
+func (t *Telemetry) buildDebugProviders(ctx context.Context, headers map[string]string) error {
+	writer := t.debugWriter
+	if writer == nil {
+		writer = os.Stderr
+	}
+	logExporter, err := stdoutlog.New(stdoutlog.WithWriter(writer), stdoutlog.WithPrettyPrint())
+	if err != nil {
+		return fmt.Errorf("telemetry: create debug log exporter: %w", err)
+	}
+	metricExporter, err := stdoutmetric.New(stdoutmetric.WithWriter(writer), stdoutmetric.WithPrettyPrint())
+	if err != nil {
+		return fmt.Errorf("telemetry: create debug metric exporter: %w", err)
+	}
+
+	if err := t.shutdownProviders(ctx); err != nil {
+		otel.Handle(err)
+	}
+	t.loggerProvider = sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewSimpleProcessor(logExporter)),
+		sdklog.WithResource(t.res),
+	)
+	interval := t.config.metricsInterval()
+	metricReader := sdkmetric.NewPeriodicReader(metricExporter,
+		sdkmetric.WithInterval(interval),
+		sdkmetric.WithTimeout(metricTimeout))
+	t.readerConfig = ReaderConfig{Interval: interval, Timeout: metricTimeout}
+	t.meterProvider = sdkmetric.NewMeterProvider(t.meterProviderOptions(metricReader)...)
+	t.logger = t.loggerProvider.Logger(instrumentationName)
+	t.headers = headers
+	t.headerSource.set(headers)
+	t.registerInstruments()
+	t.lastRebuild = time.Now()
+	t.pendingReload.Store(false)
+	t.trackComponents()
+	return nil
+}
+
+// registerInstruments registers the package's own instruments on the
+// current meter provider, reporting registration failures through the
+// otel error handler. The caller must hold t.mu.
+func (t *Telemetry) registerInstruments() {
+	if err := t.registerBuildInfo(); err != nil {
+		otel.Handle(err)
+	}
+	if err := t.registerActiveJobs(); err != nil {
+		otel.Handle(err)
+	}
+	if err := t.registerErrorCounter(); err != nil {
+		otel.Handle(err)
+	}
+	if err := t.registerIngestMetrics(); err != nil {
+		otel.Handle(err)
+	}
+	if err := t.registerQueueWait(); err != nil {
+		otel.Handle(err)
+	}
+	if err := t.registerPanicCounter(); err != nil {
+		otel.Handle(err)
+	}
+	if err := t.registerExportRatio(); err != nil {
+		otel.Handle(err)
+	}
+	if err := t.registerPendingReload(); err != nil {
+		otel.Handle(err)
+	}
+}
+
+// meterProviderOptions assembles the options for a new meter provider
+// around the given reader, registering any extra readers and applying
+// user-supplied views in place of the defaults. Temporality stays a
+// per-reader concern: extra readers keep their own selectors.
+func (t *Telemetry) meterProviderOptions(reader sdkmetric.Reader) []sdkmetric.Option {
+	opts := []sdkmetric.Option{
+		sdkmetric.WithReader(reader),
+	}
+	for _, extra := range t.config.ExtraReaders {
+		opts = append(opts, sdkmetric.WithReader(extra))
+	}
+	if t.config.MetricsDumpFile != "" {
+		dump := sdkmetric.NewPeriodicReader(&dumpMetricExporter{path: t.config.MetricsDumpFile},
+			sdkmetric.WithInterval(t.config.metricsInterval()))
+		opts = append(opts, sdkmetric.WithReader(dump))
+	}
+	t.snapshotReader = nil
+	if t.config.DumpMetricsOnShutdown {
+		t.snapshotReader = sdkmetric.NewManualReader()
+		opts = append(opts, sdkmetric.WithReader(t.snapshotReader))
+	}
+	if t.res != nil {
+		opts = append(opts, sdkmetric.WithResource(t.res))
+	}
+	for _, view := range t.config.MetricViews {
+		opts = append(opts, sdkmetric.WithView(view))
+	}
+	if t.config.MetricPrefix != "" {
+		opts = append(opts, sdkmetric.WithView(prefixView(t.config.MetricPrefix)))
+	}
+	return opts
+}
+
+// prefixView renames every instrument to carry the given prefix, so
+// runner metrics do not collide with other producers in a shared
+// backend.
+func prefixView(prefix string) sdkmetric.View {
+	return func(inst sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		return sdkmetric.Stream{
+			Name:        prefix + inst.Name,
+			Description: inst.Description,
+			Unit:        inst.Unit,
+		}, true
+	}
+}
+
+// deltaTemporality prefers delta temporality for the instrument kinds
+// that support it, matching the OTLP delta preference.
+func deltaTemporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindCounter,
+		sdkmetric.InstrumentKindObservableCounter,
+		sdkmetric.InstrumentKindHistogram:
+		return metricdata.DeltaTemporality
+	default:
+		return metricdata.CumulativeTemporality
+	}
+}
+
+// shutdownProviders shuts down the current providers, if any. The
+// caller must hold t.mu.
+func (t *Telemetry) shutdownProviders(ctx context.Context) error {
+	if t.connCancel != nil {
+		t.connCancel()
+		t.connCancel = nil
+	}
+	var err error
+	// traces flush first, before the shared connections close below.
+	if t.tracerProvider != nil {
+		if e := t.tracerProvider.Shutdown(ctx); e != nil {
+			err = e
+		}
+		t.tracerProvider = nil
+	}
+	if t.loggerProvider != nil {
+		if e := t.loggerProvider.Shutdown(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	if t.meterProvider != nil {
+		if e := t.meterProvider.Shutdown(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	if t.manualExporter != nil {
+		if e := t.manualExporter.Shutdown(ctx); e != nil && err == nil {
+			err = e
+		}
+		t.manualExporter = nil
+		t.manualReader = nil
+	}
+	t.snapshotReader = nil
+	for _, conn := range t.conns {
+		conn.Close() //nolint:errcheck
+	}
+	t.conns = nil
+	t.readerConfig = ReaderConfig{}
+	return err
+}
+
+// startupFields summarizes the effective configuration for the startup
+// log line. Header values are masked so credentials never reach the
+// log output.
+func (t *Telemetry) startupFields() logrus.Fields {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return logrus.Fields{
+		"endpoint":        t.config.Endpoint,
+		"protocol":        "grpc",
+		"logs":            true,
+		"metrics":         true,
+		"metric_interval": t.config.metricsInterval().String(),
+		"headers":         maskHeaders(t.headers),
+	}
+}
+
+// UpdateContext merges the given pairs into the context attributes
+// attached to every log record, for process-wide correlation fields
+// such as an account binding. Existing keys are overwritten; keys
+// absent from the argument are left in place.
+func (t *Telemetry) UpdateContext(attrs map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.logContext == nil {
+		t.logContext = make(map[string]string, len(attrs))
+	}
+	for k, v := range attrs {
+		t.logContext[k] = v
+	}
+}
+
+// ReplaceContext atomically swaps the entire context attribute map,
+// clearing any keys not present in the argument. Use it when a merge
+// would leave stale keys behind, for example after a rotated account
+// binding.
+func (t *Telemetry) ReplaceContext(attrs map[string]string) {
+	copied := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		copied[k] = v
+	}
+	t.mu.Lock()
+	t.logContext = copied
+	t.mu.Unlock()
+}
+
+// RemoveContextKeys deletes the given keys from the context attribute
+// map, leaving the rest in place.
+func (t *Telemetry) RemoveContextKeys(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, k := range keys {
+		delete(t.logContext, k)
+	}
+}
+
+// contextAttributes returns the current context attributes in sorted
+// key order.
+func (t *Telemetry) contextAttributes() []attribute.KeyValue {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.logContext) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(t.logContext))
+	for k := range t.logContext {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]attribute.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, attribute.String(k, t.logContext[k]))
+	}
+	return attrs
+}
+
+// SetActiveJobs records the runner's current concurrent job count. It
+// is exported as the runner.active_jobs gauge and, when EmitActiveJobs
+// is set, attached to every log record.
+func (t *Telemetry) SetActiveJobs(n int64) {
+	t.activeJobs.Store(n)
+}
+
+// WaitReady blocks until the pipeline has successfully exported at
+// least once or the context expires, so orchestration can gate traffic
+// on confirmed telemetry connectivity. Readiness is one-shot for the
+// lifetime of the Telemetry; it is not reset by Shutdown.
+func (t *Telemetry) WaitReady(ctx context.Context) error {
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// markReady records the first successful export.
+func (t *Telemetry) markReady() {
+	t.readyOnce.Do(func() { close(t.ready) })
+}
+
+// PauseMetrics withholds metric exports until ResumeMetrics is called,
+// for example during a metrics-backend maintenance window. Collections
+// still run; their results are dropped at the exporter, so log exports
+// are unaffected.
+func (t *Telemetry) PauseMetrics() {
+	t.metricsPaused.Store(true)
+}
+
+// ResumeMetrics lifts a PauseMetrics pause.
+func (t *Telemetry) ResumeMetrics() {
+	t.metricsPaused.Store(false)
+}
+
+// PauseLogs drops log records until ResumeLogs is called, leaving
+// metric exports unaffected.
+func (t *Telemetry) PauseLogs() {
+	t.logsPaused.Store(true)
+}
+
+// ResumeLogs lifts a PauseLogs pause.
+func (t *Telemetry) ResumeLogs() {
+	t.logsPaused.Store(false)
+}
+
+// registerActiveJobs registers the runner.active_jobs gauge. The
+// caller must hold t.mu and have a current meter provider.
+func (t *Telemetry) registerActiveJobs() error {
+	meter := t.meterProvider.Meter(instrumentationName)
+	gauge, err := meter.Int64ObservableGauge("runner.active_jobs",
+		metric.WithDescription("Number of jobs currently running on the runner."),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(gauge, t.activeJobs.Load())
+		return nil
+	}, gauge)
+	return err
+}
+
+// CollectMetrics collects the current metrics and exports them once.
+// It is the explicit flush companion of ManualMetricsExport and returns
+// an error when that mode is not enabled.
+func (t *Telemetry) CollectMetrics(ctx context.Context) error {
+	t.mu.RLock()
+	reader, exporter := t.manualReader, t.manualExporter
+	t.mu.RUnlock()
+	if reader == nil {
+		return errors.New("telemetry: ManualMetricsExport is not enabled")
+	}
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		return err
+	}
+	return exporter.Export(ctx, &rm)
+}
+
+// registerErrorCounter creates the errors_total counter the hook
+// increments for every error-and-above entry, so each deployment gets
+// an error-rate metric without custom instrumentation. The caller must
+// hold t.mu and have a current meter provider.
+func (t *Telemetry) registerErrorCounter() error {
+	counter, err := t.meterProvider.Meter(instrumentationName).Int64Counter("errors_total",
+		metric.WithDescription("Count of error-and-above log entries emitted by the runner."),
+	)
+	if err != nil {
+		return err
+	}
+	t.errorsTotal = counter
+	return nil
+}
+
+// registerIngestMetrics creates the instruments that surface ingest
+// cost: a gauge for the resource attribute count and histograms of
+// per-record attribute counts and body sizes. The caller must hold
+// t.mu and have a current meter provider.
+func (t *Telemetry) registerIngestMetrics() error {
+	meter := t.meterProvider.Meter(instrumentationName)
+	gauge, err := meter.Int64ObservableGauge("otel.resource.attributes",
+		metric.WithDescription("Number of resource attributes attached to every export."),
+	)
+	if err != nil {
+		return err
+	}
+	// the gauge observes the resource of this provider generation; a
+	// rebuild re-registers it against the new resource.
+	res := t.res
+	if _, err := meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(gauge, int64(len(res.Attributes())))
+		return nil
+	}, gauge); err != nil {
+		return err
+	}
+	attrsHist, err := meter.Int64Histogram("otel.log.record.attributes",
+		metric.WithDescription("Number of attributes per emitted log record."),
+	)
+	if err != nil {
+		return err
+	}
+	bodyHist, err := meter.Int64Histogram("otel.log.record.body.size",
+		metric.WithDescription("Body size per emitted log record."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+	t.recordAttrsHist = attrsHist
+	t.recordBodyHist = bodyHist
+	return nil
+}
+
+// registerPanicCounter creates the counter of panics recovered in the
+// log hook. The caller must hold t.mu and have a current meter
+// provider.
+func (t *Telemetry) registerPanicCounter() error {
+	counter, err := t.meterProvider.Meter(instrumentationName).Int64Counter("otel_log_hook_panics_total",
+		metric.WithDescription("Count of panics recovered in the log hook."),
+	)
+	if err != nil {
+		return err
+	}
+	t.hookPanics = counter
+	return nil
+}
+
+// registerExportRatio registers the otel_export_success_ratio gauge,
+// the fraction of exports that succeeded within the sliding window.
+// Nothing is observed while no export fell inside the window, so an
+// idle pipeline does not read as perfectly healthy. The caller must
+// hold t.mu and have a current meter provider.
+func (t *Telemetry) registerExportRatio() error {
+	meter := t.meterProvider.Meter(instrumentationName)
+	gauge, err := meter.Float64ObservableGauge("otel_export_success_ratio",
+		metric.WithDescription("Fraction of telemetry exports that succeeded over the last five minutes."),
+	)
+	if err != nil {
+		return err
+	}
+	results := t.exportResults
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		if ratio, ok := results.ratio(); ok {
+			o.ObserveFloat64(gauge, ratio)
+		}
+		return nil
+	}, gauge)
+	return err
+}
+
+// registerPendingReload registers the otel_config_pending_reload
+// gauge: 1 while a configuration change was detected but not applied —
+// a Start or Reconfigure skipped by MinReconfigureInterval — and 0
+// otherwise, so operators know a reload is queued. The caller must
+// hold t.mu and have a current meter provider.
+func (t *Telemetry) registerPendingReload() error {
+	meter := t.meterProvider.Meter(instrumentationName)
+	gauge, err := meter.Int64ObservableGauge("otel_config_pending_reload",
+		metric.WithDescription("Whether a configuration change is waiting to be applied."),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		var pending int64
+		if t.pendingReload.Load() {
+			pending = 1
+		}
+		o.ObserveInt64(gauge, pending)
+		return nil
+	}, gauge)
+	return err
+}
+
+// recordHookPanic accounts for a panic recovered in the hook. Every
+// occurrence increments otel_log_hook_panics_total; the first one is
+// also logged, once, so the failure is visible even when metrics are
+// not being watched.
+func (t *Telemetry) recordHookPanic(r interface{}) {
+	t.mu.RLock()
+	counter := t.hookPanics
+	t.mu.RUnlock()
+	if counter != nil {
+		counter.Add(context.Background(), 1)
+	}
+	t.panicLogOnce.Do(func() {
+		logrus.WithField("panic", fmt.Sprint(r)).Error("telemetry: recovered panic in log hook")
+	})
+}
+
+// registerQueueWait creates the queue-wait histogram fed by
+// RecordQueueWait. The caller must hold t.mu and have a current meter
+// provider.
+func (t *Telemetry) registerQueueWait() error {
+	hist, err := t.meterProvider.Meter(instrumentationName).Float64Histogram("runner_queue_wait_seconds",
+		metric.WithDescription("Time jobs wait before a VM is available."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+	t.queueWaitHist = hist
+	return nil
+}
+
+// RecordQueueWait records how long a job waited before a VM became
+// available, exported as the runner_queue_wait_seconds histogram with
+// the given status attribute. It is a no-op while the pipeline is
+// stopped.
+func (t *Telemetry) RecordQueueWait(ctx context.Context, d time.Duration, status string) {
+	t.mu.RLock()
+	hist := t.queueWaitHist
+	t.mu.RUnlock()
+	if hist == nil {
+		return
+	}
+	hist.Record(ctx, d.Seconds(), metric.WithAttributes(attribute.String("status", status)))
+}
+
+// ingestHistograms returns the per-record size histograms, or nils
+// while the pipeline is stopped.
+func (t *Telemetry) ingestHistograms() (attrs, body metric.Int64Histogram) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.recordAttrsHist, t.recordBodyHist
+}
+
+// errorCounter returns the errors_total counter, or nil while the
+// pipeline is stopped.
+func (t *Telemetry) errorCounter() metric.Int64Counter {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.errorsTotal
+}
+
+// emitActiveJobs reports whether log records carry the active job
+// count.
+func (t *Telemetry) emitActiveJobs() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.EmitActiveJobs
+}
+
+// hostShortname derives the machine's non-FQDN short hostname, or an
+// empty string when it cannot be determined.
+func hostShortname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	short, _, _ := strings.Cut(name, ".")
+	return short
+}
+
+// emitHostShortname reports whether log records carry the short
+// hostname.
+func (t *Telemetry) emitHostShortname() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.EmitHostShortname
+}
+
+// emitTraceFlags reports whether log records emitted inside a span
+// carry the span's trace flags.
+func (t *Telemetry) emitTraceFlags() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.EmitTraceFlags
+}
+
+// emitMessageBytes reports whether log records carry the byte length of
+// their message as an attribute.
+func (t *Telemetry) emitMessageBytes() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.EmitMessageBytes
+}
+
+// mapHTTPSemconv reports whether well-known HTTP entry fields are
+// renamed to their semantic-convention equivalents.
+func (t *Telemetry) mapHTTPSemconv() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.MapHTTPSemconv
+}
+
+// spanForLevel reports whether entries at the given level without an
+// active span are wrapped in one. With SpanLevels unset, the feature
+// covers error-and-above.
+func (t *Telemetry) spanForLevel(level logrus.Level) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.config.SpanForErrorLogs {
+		return false
+	}
+	if len(t.config.SpanLevels) == 0 {
+		return level <= logrus.ErrorLevel
+	}
+	for _, name := range t.config.SpanLevels {
+		if parsed, err := logrus.ParseLevel(name); err == nil && parsed == level {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveConfig returns an immutable snapshot of the configuration in
+// use. Mutating the returned value never affects the running pipeline.
+func (t *Telemetry) ActiveConfig() Config {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.Clone()
+}
+
+// ReaderConfig returns the interval and timeout the periodic metrics
+// reader was built with, so operators and tests can confirm tuning
+// took effect. It returns the zero value before Start and when
+// ManualMetricsExport is set, since a manual reader has neither.
+func (t *Telemetry) ReaderConfig() ReaderConfig {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.readerConfig
+}
+
+// MetricExportInterval returns the metric export interval currently in
+// force. With AdaptiveExportBackoff it reflects the stretched interval
+// while the collector is throttling; otherwise it equals the reader's
+// configured interval.
+func (t *Telemetry) MetricExportInterval() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.backoff != nil {
+		return t.backoff.interval()
+	}
+	return t.readerConfig.Interval
+}
+
+// GetTracerProvider returns the tracer provider exporting spans, or
+// nil when ExportTraces is disabled or the pipeline is stopped. The
+// provider is also registered globally, so most callers can keep using
+// otel.Tracer.
+func (t *Telemetry) GetTracerProvider() *sdktrace.TracerProvider {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.tracerProvider
+}
+
+// GetResource returns a copy of the resource attached to exports, or
+// nil before Start. The copy is safe to mutate.
+func (t *Telemetry) GetResource() *resource.Resource {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.res == nil {
+		return nil
+	}
+	res := *t.res
+	return &res
+}
+
+// sampleRatio returns the configured log sample ratio.
+func (t *Telemetry) sampleRatio() float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.LogSampleRatio
+}
+
+// inheritDeadline reports whether emits inherit the entry context's
+// deadline.
+func (t *Telemetry) inheritDeadline() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.emitInheritsDeadline()
+}
+
+// duplicateStrategy returns the configured duplicate attribute
+// strategy.
+func (t *Telemetry) duplicateStrategy() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.DuplicateAttributeStrategy
+}
+
+// bodyFormat returns the configured log body format.
+func (t *Telemetry) bodyFormat() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.LogBodyFormat
+}
+
+// elevateLogsWithError returns whether entries with an error field are
+// exported at elevated severity.
+func (t *Telemetry) elevateLogsWithError() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.ElevateLogsWithError
+}
+
+// jsonEncodeComplexFields returns whether map and slice fields are
+// exported as JSON attribute values.
+func (t *Telemetry) jsonEncodeComplexFields() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.JSONEncodeComplexFields
+}
+
+// maxBodyLength returns the configured record body length cap.
+func (t *Telemetry) maxBodyLength() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config.MaxBodyLength
+}
+
+// emitLogger returns the logger the hook should emit to, or nil when
+// the pipeline is stopped.
+func (t *Telemetry) emitLogger() log.Logger {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.logger
+}
+
+// acquireEmitSlot reserves a slot in the emit semaphore, waiting up to
+// EmitAcquireTimeout when saturated. The returned release must be
+// called once the emit completes. Without a configured bound the
+// acquisition is a no-op that always succeeds.
+func (t *Telemetry) acquireEmitSlot(ctx context.Context) (release func(), ok bool) {
+	t.mu.RLock()
+	slots := t.emitSlots
+	timeout := t.config.EmitAcquireTimeout
+	t.mu.RUnlock()
+	if slots == nil {
+		return func() {}, true
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+	}
+	if timeout <= 0 {
+		return nil, false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	case <-timer.C:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
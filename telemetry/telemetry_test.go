@@ -0,0 +1,574 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+	"go.opentelemetry.io/otel/metric"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestStartupSummaryLog(t *testing.T) {
+	hook := logrustest.NewGlobal()
+	defer hook.Reset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+		Headers:     map[string]string{"Authorization": "Bearer secret-token"},
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}()
+
+	var entry *logrus.Entry
+	for _, e := range hook.AllEntries() {
+		if e.Message == "telemetry: started" {
+			entry = e
+		}
+	}
+	if entry == nil {
+		t.Fatal("no startup summary log emitted")
+	}
+	want := logrus.Fields{
+		"endpoint":        "127.0.0.1:4317",
+		"protocol":        "grpc",
+		"logs":            true,
+		"metrics":         true,
+		"metric_interval": "30s",
+		"headers":         "Authorization=***",
+	}
+	for k, v := range want {
+		if entry.Data[k] != v {
+			t.Errorf("startup field %q = %v, want %v", k, entry.Data[k], v)
+		}
+	}
+	if strings.Contains(fmt.Sprint(entry.Data), "secret-token") {
+		t.Error("startup summary leaks header secret")
+	}
+}
+
+func TestReplaceAndRemoveContext(t *testing.T) {
+	tel := New(Config{})
+	rec := &recordingLogger{}
+	tel.logger = rec
+
+	fire := func() map[string]interface{} {
+		t.Helper()
+		rec.records = nil
+		entry := &logrus.Entry{Message: "context check", Data: logrus.Fields{}}
+		if err := tel.hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+		return recordAttributes(rec.records[0])
+	}
+
+	// updates merge, overwriting existing keys.
+	tel.UpdateContext(map[string]string{"account": "one", "region": "us-east-1"})
+	tel.UpdateContext(map[string]string{"account": "two"})
+	attrs := fire()
+	if attrs["account"] != "two" || attrs["region"] != "us-east-1" {
+		t.Errorf("merged context = %v, want account=two region=us-east-1", attrs)
+	}
+
+	// a replace clears keys absent from the new map.
+	tel.ReplaceContext(map[string]string{"account": "three"})
+	attrs = fire()
+	if attrs["account"] != "three" {
+		t.Errorf("account = %v, want three", attrs["account"])
+	}
+	if _, ok := attrs["region"]; ok {
+		t.Error("region survived ReplaceContext")
+	}
+
+	// a remove deletes specific keys only.
+	tel.UpdateContext(map[string]string{"region": "us-west-2"})
+	tel.RemoveContextKeys("account")
+	attrs = fire()
+	if _, ok := attrs["account"]; ok {
+		t.Error("account survived RemoveContextKeys")
+	}
+	if attrs["region"] != "us-west-2" {
+		t.Errorf("region = %v, want us-west-2", attrs["region"])
+	}
+
+	// concurrent mutation and reads must be safe.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				tel.UpdateContext(map[string]string{"worker": fmt.Sprint(i)})
+				tel.ReplaceContext(map[string]string{"worker": fmt.Sprint(i)})
+				tel.RemoveContextKeys("worker")
+				tel.contextAttributes()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRegisterShutdownFunc(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	called := false
+	sentinel := errors.New("custom sink flush failed")
+	tel.RegisterShutdownFunc(func(context.Context) error {
+		called = true
+		return sentinel
+	})
+
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	err := tel.Shutdown(shutCtx)
+	if !called {
+		t.Error("registered shutdown func was not called")
+	}
+	if !errors.Is(err, sentinel) {
+		t.Errorf("Shutdown() error = %v, want aggregation containing %v", err, sentinel)
+	}
+}
+
+func TestShutdownReport(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(srv, &captureLogsServer{md: make(chan metadata.MD, 8)})
+	go srv.Serve(lis) //nolint:errcheck
+	defer srv.Stop()
+
+	tel := New(Config{
+		Endpoint:    lis.Addr().String(),
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+		// the stub only serves the logs service.
+		CollectOnShutdown: boolPtr(false),
+	})
+	if err := tel.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	const entries = 3
+	for i := 0; i < entries; i++ {
+		entry := &logrus.Entry{Time: time.Now(), Level: logrus.InfoLevel, Message: "flush check", Data: logrus.Fields{}}
+		if err := tel.hook.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	report, err := tel.ShutdownWithReport(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.RecordsFlushed != entries {
+		t.Errorf("RecordsFlushed = %d, want %d", report.RecordsFlushed, entries)
+	}
+	if report.RecordsDropped != 0 {
+		t.Errorf("RecordsDropped = %d, want 0", report.RecordsDropped)
+	}
+}
+
+func TestMetricsInitialDelay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:            "127.0.0.1:4317",
+		Insecure:            true,
+		ServiceName:         "drone-runner-aws",
+		MetricsInitialDelay: 100 * time.Millisecond,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer tel.Shutdown(ctx) //nolint:errcheck
+
+	started := time.Now()
+	collected := make(chan time.Time, 1)
+	meter := tel.meterProvider.Meter("test")
+	_, err := meter.Int64ObservableCounter("test.collections",
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			select {
+			case collected <- time.Now():
+			default:
+			}
+			o.Observe(1)
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the forced flush must collect well before the 30s steady-state
+	// interval elapses.
+	select {
+	case at := <-collected:
+		if elapsed := at.Sub(started); elapsed > 5*time.Second {
+			t.Errorf("first collection after %v, want within the initial delay", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no metrics collection within the initial delay")
+	}
+}
+
+// countingListener counts the connections it accepts.
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int64
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+func TestLazyInit(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counting := &countingListener{Listener: lis}
+	captured := make(chan metadata.MD, 1)
+	srv := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(srv, &captureLogsServer{md: captured})
+	go srv.Serve(counting) //nolint:errcheck
+	defer srv.Stop()
+
+	tel := New(Config{
+		Endpoint:    lis.Addr().String(),
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+		LazyInit:    true,
+	})
+	if err := tel.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		tel.Shutdown(ctx) //nolint:errcheck
+	}()
+
+	// nothing may touch the collector until the first record.
+	time.Sleep(200 * time.Millisecond)
+	if got := counting.accepted.Load(); got != 0 {
+		t.Fatalf("collector saw %d connections before the first emit, want 0", got)
+	}
+	if tel.emitLogger() != nil {
+		t.Fatal("logger built before the first emit")
+	}
+
+	entry := &logrus.Entry{Time: time.Now(), Level: logrus.InfoLevel, Message: "first emit", Data: logrus.Fields{}}
+	if err := tel.hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-captured:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no export received after the first emit")
+	}
+	if got := counting.accepted.Load(); got == 0 {
+		t.Error("collector saw no connection after the first emit")
+	}
+	if tel.emitLogger() == nil {
+		t.Error("logger not built by the first emit")
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	tel := New(Config{})
+	exporter := &accountingLogExporter{
+		Exporter: &flakyLogExporter{failures: 1},
+		flushed:  &tel.recordsFlushed,
+		dropped:  &tel.recordsDropped,
+		ready:    tel.markReady,
+	}
+
+	// before any successful export, WaitReady times out.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	if err := tel.WaitReady(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitReady() = %v, want deadline exceeded", err)
+	}
+	cancel()
+
+	// a failed export does not mark the pipeline ready.
+	if err := exporter.Export(context.Background(), nil); err == nil {
+		t.Fatal("Export() = nil, want error")
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	if err := tel.WaitReady(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitReady() after failed export = %v, want deadline exceeded", err)
+	}
+	cancel()
+
+	// the first success, even a delayed one, releases the wait.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		exporter.Export(context.Background(), nil) //nolint:errcheck
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tel.WaitReady(ctx); err != nil {
+		t.Errorf("WaitReady() = %v, want nil after a successful export", err)
+	}
+}
+
+func TestMinReconfigureInterval(t *testing.T) {
+	hook := logrustest.NewGlobal()
+	defer hook.Reset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:               "127.0.0.1:4317",
+		Insecure:               true,
+		ServiceName:            "drone-runner-aws",
+		MinReconfigureInterval: time.Minute,
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a reconfigure right after the initial build is skipped.
+	next := tel.ActiveConfig()
+	next.Endpoint = "127.0.0.1:4318"
+	if err := tel.Reconfigure(ctx, next); err != nil {
+		t.Fatal(err)
+	}
+	if got := tel.ActiveConfig().Endpoint; got != "127.0.0.1:4317" {
+		t.Errorf("endpoint after debounced reconfigure = %q, want unchanged", got)
+	}
+	warned := false
+	for _, e := range hook.AllEntries() {
+		if e.Level == logrus.WarnLevel && strings.Contains(e.Message, "reconfigure skipped") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Error("debounced reconfigure did not log a warning")
+	}
+
+	// a rapid stop/start cycle is debounced the same way.
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tel.mu.RLock()
+	restarted := tel.started
+	tel.mu.RUnlock()
+	if restarted {
+		t.Error("rapid restart was not debounced")
+	}
+
+	// without the option every rebuild goes through.
+	plain := New(Config{
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+	})
+	if err := plain.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Shutdown(shutCtx) //nolint:errcheck
+	next = plain.ActiveConfig()
+	next.Endpoint = "127.0.0.1:4318"
+	if err := plain.Reconfigure(ctx, next); err != nil {
+		t.Fatal(err)
+	}
+	if got := plain.ActiveConfig().Endpoint; got != "127.0.0.1:4318" {
+		t.Errorf("endpoint after reconfigure = %q, want 127.0.0.1:4318", got)
+	}
+}
+
+// syncWriter serializes writes from the emit path and the periodic
+// reader so the test can read the buffer safely.
+type syncWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *syncWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestDebugMode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// no endpoint: debug mode must not require one.
+	out := &syncWriter{}
+	tel := New(Config{Debug: true, ServiceName: "drone-runner-aws"})
+	tel.debugWriter = out
+	if err := tel.Start(ctx); err != nil {
+		t.Fatalf("Start() = %v in debug mode", err)
+	}
+
+	entry := &logrus.Entry{Message: "debug mode check", Level: logrus.InfoLevel, Data: logrus.Fields{}}
+	if err := tel.hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "debug mode check") {
+		t.Error("log record not written to the debug writer")
+	}
+
+	// the shutdown collect flushes the registered metrics too.
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+	if !strings.Contains(out.String(), "runner_build_info") {
+		t.Error("metrics not written to the debug writer on shutdown")
+	}
+}
+
+func TestDumpMetricsOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out := &syncWriter{}
+	tel := New(Config{
+		Endpoint:              "127.0.0.1:4317",
+		Insecure:              true,
+		ServiceName:           "drone-runner-aws",
+		DumpMetricsOnShutdown: true,
+	})
+	tel.snapshotWriter = out
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	counter, err := tel.meterProvider.Meter("test").Int64Counter("runner.jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 4)
+
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+
+	var snap metricSnapshot
+	if err := json.Unmarshal([]byte(out.String()), &snap); err != nil {
+		t.Fatalf("snapshot is not valid JSON: %v", err)
+	}
+	values := map[string]interface{}{}
+	for _, m := range snap.Metrics {
+		if len(m.Points) > 0 {
+			values[m.Name] = m.Points[0].Value
+		}
+	}
+	// JSON numbers decode as float64.
+	if v, ok := values["runner.jobs"]; !ok || v != float64(4) {
+		t.Errorf("runner.jobs = %v (present=%v), want 4", v, ok)
+	}
+	if _, ok := values["runner_build_info"]; !ok {
+		t.Error("registered instruments missing from the snapshot")
+	}
+}
+
+func TestReconfigureWarnsOnWatcherChange(t *testing.T) {
+	hook := logrustest.NewGlobal()
+	defer hook.Reset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutCancel()
+		tel.Shutdown(shutCtx) //nolint:errcheck
+	}()
+
+	file := filepath.Join(t.TempDir(), "headers")
+	if err := os.WriteFile(file, []byte("authorization=token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	next := tel.ActiveConfig()
+	next.HeadersFile = file
+	next.HeaderRefreshInterval = time.Minute
+	if err := tel.Reconfigure(ctx, next); err != nil {
+		t.Fatal(err)
+	}
+	warned := false
+	for _, e := range hook.AllEntries() {
+		if e.Level == logrus.WarnLevel && strings.Contains(e.Message, "does not relaunch background workers") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Error("watcher-backed config change did not log a warning")
+	}
+
+	// an unchanged watcher configuration stays quiet.
+	hook.Reset()
+	next = tel.ActiveConfig()
+	next.Environment = "staging"
+	if err := tel.Reconfigure(ctx, next); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range hook.AllEntries() {
+		if strings.Contains(e.Message, "does not relaunch background workers") {
+			t.Error("unchanged watcher configuration logged a warning")
+		}
+	}
+}
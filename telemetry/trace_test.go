@@ -0,0 +1,59 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestExportTraces(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tel := New(Config{
+		Endpoint:          "127.0.0.1:4317",
+		Insecure:          true,
+		ServiceName:       "drone-runner-aws",
+		ExportTraces:      true,
+		CollectOnShutdown: boolPtr(false),
+	})
+	if err := tel.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := tel.GetTracerProvider()
+	if provider == nil {
+		t.Fatal("GetTracerProvider() = nil with ExportTraces enabled")
+	}
+	if otel.GetTracerProvider() != provider {
+		t.Error("tracer provider not registered globally")
+	}
+	_, span := provider.Tracer("test").Start(ctx, "provision")
+	span.End()
+
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutCancel()
+	tel.Shutdown(shutCtx) //nolint:errcheck
+	if tel.GetTracerProvider() != nil {
+		t.Error("tracer provider survives Shutdown")
+	}
+
+	// without the flag no tracer provider is built.
+	plain := New(Config{
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "drone-runner-aws",
+	})
+	if err := plain.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Shutdown(shutCtx) //nolint:errcheck
+	if plain.GetTracerProvider() != nil {
+		t.Error("tracer provider built without ExportTraces")
+	}
+}
@@ -0,0 +1,66 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// mismatchHint is appended to validation errors whose transport failure
+// looks like a gRPC client talking to an HTTP OTLP port.
+const mismatchHint = "endpoint looks like an HTTP OTLP port; point Endpoint at the collector's gRPC port (default 4317)"
+
+// validateTimeout bounds the probe export, so a misconfigured endpoint
+// fails Start quickly instead of waiting out the caller's deadline.
+const validateTimeout = 5 * time.Second
+
+// validateEndpoint performs a single probe export over the freshly
+// dialed collector connection, so a misconfigured endpoint fails Start
+// with a descriptive error instead of failing silently on every later
+// export. The caller must hold t.mu and have a current connection.
+func (t *Telemetry) validateEndpoint(ctx context.Context) error {
+	exporter, err := otlploggrpc.New(ctx,
+		otlploggrpc.WithGRPCConn(t.conns[0]),
+		otlploggrpc.WithRetry(otlploggrpc.RetryConfig{Enabled: false}),
+	)
+	if err != nil {
+		return fmt.Errorf("telemetry: validate endpoint %q: %w", t.config.Endpoint, err)
+	}
+	defer exporter.Shutdown(ctx) //nolint:errcheck
+
+	probeCtx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+	var record sdklog.Record
+	record.SetTimestamp(time.Now())
+	record.SetBody(attribute.StringValue("telemetry: endpoint probe"))
+	err = exporter.Export(probeCtx, []sdklog.Record{record})
+	if err == nil {
+		return nil
+	}
+	if protocolMismatch(err) {
+		return fmt.Errorf("telemetry: validate endpoint %q: %w (%s)", t.config.Endpoint, err, mismatchHint)
+	}
+	return fmt.Errorf("telemetry: validate endpoint %q: %w", t.config.Endpoint, err)
+}
+
+// protocolMismatch reports whether the export error looks like the
+// gRPC client reached an HTTP endpoint, the usual symptom of pointing
+// the exporter at the collector's HTTP OTLP port (4318).
+func protocolMismatch(err error) bool {
+	msg := err.Error()
+	for _, symptom := range []string{
+		"malformed header: missing HTTP content-type",
+		"unexpected content-type",
+		"http2: frame too large",
+	} {
+		if strings.Contains(msg, symptom) {
+			return true
+		}
+	}
+	return false
+}
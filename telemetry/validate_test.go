@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateEndpointMismatchedPort(t *testing.T) {
+	// a plain HTTP server stands in for the collector's HTTP OTLP port.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tel := New(Config{
+		Endpoint:         strings.TrimPrefix(server.URL, "http://"),
+		Insecure:         true,
+		ServiceName:      "drone-runner-aws",
+		ValidateEndpoint: true,
+	})
+	err := tel.Start(ctx)
+	if err == nil {
+		tel.Shutdown(ctx) //nolint:errcheck
+		t.Fatal("Start succeeded against an HTTP endpoint")
+	}
+	if !strings.Contains(err.Error(), mismatchHint) {
+		t.Errorf("Start error %q does not carry the protocol-mismatch hint", err)
+	}
+	// a failed validation leaves the pipeline stopped.
+	if tel.emitLogger() != nil {
+		t.Error("logger still live after failed validation")
+	}
+}
+
+func TestProtocolMismatch(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "missing content type",
+			err:  errors.New(`unexpected HTTP status code received from server: 200 (OK); malformed header: missing HTTP content-type`),
+			want: true,
+		},
+		{
+			name: "wrong content type",
+			err:  errors.New(`transport: received unexpected content-type "text/plain"`),
+			want: true,
+		},
+		{
+			name: "oversized frame",
+			err:  errors.New("http2: frame too large"),
+			want: true,
+		},
+		{
+			name: "plain connection refused",
+			err:  errors.New("connection refused"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := protocolMismatch(tt.err); got != tt.want {
+				t.Errorf("protocolMismatch(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}